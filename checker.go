@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/failure"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/metrics"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/formatter"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/scheduler"
+)
+
+// commitTimeout bounds saving and sending the results of a check, which
+// are finished even after shutdown was requested.
+const commitTimeout = 30 * time.Second
+
+// cycles counts the checks of the process, the number is logged as the
+// cycle of every record of a check.
+var cycles atomic.Int64
+
+// checker runs a single check: fetches grades, compares them with the saved
+// ones and sends the changes. A dry run neither saves the grades nor waits
+// for quiet hours to end, its notifyer is expected to print the messages.
+type checker struct {
+	dryRun   bool
+	cfg      config.Config
+	log      *slog.Logger
+	notifyer *notifyer.Notifyer
+	schedule scheduler.Scheduler
+	digest   *course.Digest
+	fetcher  gradesFetcher
+	saveCfg  course.SaveConfig
+}
+
+// gradesFetcher logs in to moodle and fetches grades of the tracked courses.
+type gradesFetcher struct {
+	cfg          config.Config
+	log          *slog.Logger
+	moodleClient *moodle.Client
+	fetchCfg     moodle.FetchConfig
+	courseFilter moodle.CourseFilter
+}
+
+func newChecker(
+	cfg config.Config,
+	notifyer *notifyer.Notifyer,
+	schedule scheduler.Scheduler,
+	digest *course.Digest,
+	dryRun bool,
+) checker {
+	return checker{
+		dryRun:   dryRun,
+		cfg:      cfg,
+		log:      cfg.Logger,
+		notifyer: notifyer,
+		schedule: schedule,
+		digest:   digest,
+		fetcher:  newGradesFetcher(cfg),
+		saveCfg: course.SaveConfig{
+			LastGradesPath:    cfg.LastGradesPath,
+			GradesHistoryPath: cfg.GradesHistoryPath,
+		},
+	}
+}
+
+func newGradesFetcher(cfg config.Config) gradesFetcher {
+	return gradesFetcher{
+		cfg: cfg,
+		log: cfg.Logger,
+		moodleClient: moodle.NewClient(moodle.ClientConfig{
+			BaseURL:           cfg.MoodleURL,
+			Timeout:           cfg.RequestTimeout,
+			Retries:           cfg.RequestRetries,
+			RequestsPerSecond: cfg.RequestsPerSecond,
+		}, cfg.Logger),
+		fetchCfg: moodle.FetchConfig{
+			Workers:          cfg.Workers,
+			CourseTimeout:    cfg.CourseTimeout,
+			FullRefreshEvery: cfg.FullRefreshEvery,
+		},
+		courseFilter: moodle.CourseFilter{
+			Classification: cfg.CourseClassification,
+			GracePeriod:    cfg.CourseGracePeriod,
+			Include:        cfg.IncludeCourses,
+			Exclude:        cfg.ExcludeCourses,
+		},
+	}
+}
+
+// check returns errors classified by the failure package. The snapshot is
+// saved once the updates are sent, so the changes a notifier failed to send
+// are sent by the next check. Its records are logged with the cycle and the
+// user.
+func (c checker) check(ctx context.Context) error {
+	ctx = logging.With(ctx, logging.KeyCycle, cycles.Add(1), logging.KeyUser, c.cfg.MoodleCredentials().Login())
+
+	start := time.Now()
+	c.log.InfoContext(ctx, "check started")
+
+	err := c.run(ctx)
+	duration := time.Since(start)
+	if err != nil {
+		reason := failure.KindOf(err)
+		metrics.CheckFailed(string(reason), duration)
+		c.log.ErrorContext(ctx, "check failed", "reason", reason, logging.KeyDuration, duration, logging.Err(err))
+		return err
+	}
+	metrics.CheckSucceeded(duration)
+	c.log.InfoContext(ctx, "check finished", logging.KeyDuration, duration)
+
+	return nil
+}
+
+func (c checker) run(ctx context.Context) error {
+	grades := course.NewGrades(c.saveCfg, logging.WithContext(c.log, ctx))
+
+	previousGrades, err := grades.GetSaved()
+	if err != nil {
+		c.log.WarnContext(ctx, "failed to get saved grades", logging.Err(err))
+	}
+
+	coursesGrades, err := c.fetcher.fetch(ctx, previousGrades)
+	if err != nil {
+		return failure.Moodle(err)
+	}
+
+	// the grades are fetched, from here on the check is committed even if ctx
+	// is cancelled, so the snapshot and the notifications stay consistent.
+	// The log fields of ctx are kept.
+	commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), commitTimeout)
+	defer cancel()
+
+	isQuiet := c.schedule.IsQuiet(time.Now()) && !c.dryRun
+
+	var notifyErr error
+
+	warnings := moodle.CollectWarnings(coursesGrades)
+	for _, warning := range warnings {
+		c.log.WarnContext(ctx, "failed to parse grades", logging.KeyCourseID, warning.CourseID, logging.KeyCourse, warning.CourseName, "row", warning.RowID, logging.Err(warning.Err))
+	}
+
+	if !isQuiet {
+		_, err = c.notifyer.SendWarnings(commitCtx, formatter.ConvertWarnings(warnings))
+		if err != nil {
+			notifyErr = err
+		}
+	}
+
+	coursesGrades = grades.RestoreUnavailable(coursesGrades)
+
+	gradeChanges, err := grades.Compare(coursesGrades)
+	if err != nil {
+		return failure.Parse(err)
+	}
+
+	metrics.ChangesDetected(course.CountChanges(gradeChanges))
+	c.log.InfoContext(ctx, "compared grades", "courses", len(coursesGrades), "changed_courses", len(gradeChanges))
+
+	if isQuiet {
+		c.digest.Open(previousGrades)
+		c.save(ctx, grades, coursesGrades, gradeChanges)
+		c.log.InfoContext(ctx, "quiet hours, changes are queued for the digest")
+
+		return nil
+	}
+
+	updates := gradeChanges
+	if c.digest.IsOpen() && !c.dryRun {
+		updates = c.digest.Pending(coursesGrades)
+		c.log.InfoContext(ctx, "sending digest", "changed_courses", len(updates))
+	}
+
+	messagesSended, err := c.notifyer.SendUpdates(
+		commitCtx,
+		formatter.ConvertCourseGradesChange(updates),
+	)
+	c.log.InfoContext(ctx, "sent updates", "messages", messagesSended)
+	if err != nil {
+		// the snapshot is not saved, so the changes are found and sent again
+		// by the next check, the digest stays open for the same reason. The
+		// messages that were delivered are not sent twice
+		return failure.Notifier(err)
+	}
+
+	if c.digest.IsOpen() && !c.dryRun {
+		c.digest.Close(coursesGrades)
+	}
+	c.save(ctx, grades, coursesGrades, gradeChanges)
+
+	if c.cfg.SendOverview && course.HasTotalChanges(updates) {
+		_, err = c.notifyer.SendOverview(commitCtx, formatter.ConvertCourses(coursesGrades))
+		if err != nil {
+			notifyErr = err
+		}
+	}
+
+	if notifyErr != nil {
+		return failure.Notifier(notifyErr)
+	}
+
+	return nil
+}
+
+// save saves the snapshot and the history of its changes, a dry run saves
+// nothing.
+func (c checker) save(ctx context.Context, grades course.Grades, coursesGrades []moodle.Course, gradeChanges []course.CourseGradesChange) {
+	if c.dryRun {
+		return
+	}
+
+	err := grades.Save(coursesGrades)
+	if err != nil {
+		c.log.ErrorContext(ctx, "failed to save grades", logging.Err(err))
+	}
+
+	err = grades.SaveHistory(gradeChanges, time.Now())
+	if err != nil {
+		c.log.ErrorContext(ctx, "failed to save grades history", logging.Err(err))
+	}
+}
+
+// fetch returns grades of the tracked courses, grades of the courses that
+// have not changed since previous may be taken from it.
+func (f gradesFetcher) fetch(ctx context.Context, previous []moodle.Course) ([]moodle.Course, error) {
+	token, err := moodle.GetTokens(ctx, f.moodleClient, f.cfg.MoodleCredentials(), f.cfg.Dumps(), f.log)
+	if err != nil {
+		return nil, err
+	}
+
+	moodleAPI, err := moodle.NewMoodle(ctx, f.moodleClient.WithToken(token), f.fetchCfg, f.log)
+	if err != nil {
+		return nil, err
+	}
+
+	f.log.InfoContext(ctx, "getting moodle grades")
+
+	return moodleAPI.WithSnapshot(previous).GetTrackedCourses(ctx, f.courseFilter)
+}
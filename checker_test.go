@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/failure"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/formatter"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/telegram"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/scheduler"
+)
+
+// fakeMoodle serves one course whose grade of Lab 4 out of 10 is grade, the
+// percentage of the row follows it.
+type fakeMoodle struct {
+	grade atomic.Value
+}
+
+func newFakeMoodle(t *testing.T) (*fakeMoodle, *httptest.Server) {
+	gradesTable, err := os.ReadFile("pkg/moodle/testdata/gradereport_user_get_grades_table.json")
+	assert.NoError(t, err)
+
+	fake := &fakeMoodle{}
+	fake.grade.Store("8")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+
+		switch r.PostForm.Get("wsfunction") {
+		case "core_webservice_get_site_info":
+			w.Write([]byte(`{"userid": 7}`))
+		case "core_enrol_get_users_courses":
+			w.Write([]byte(`[{"id": 42, "fullname": "AGLA"}]`))
+		case "gradereport_user_get_grades_table":
+			grade := fake.grade.Load().(string)
+			points, err := strconv.ParseFloat(grade, 64)
+			assert.NoError(t, err)
+
+			table := strings.Replace(string(gradesTable), `"content": "8.00"`, `"content": "`+grade+`"`, 1)
+			table = strings.Replace(table, `"content": "80.00 %"`, fmt.Sprintf(`"content": "%.2f %%"`, points*10), 1)
+			w.Write([]byte(table))
+		case "gradereport_overview_get_course_grades":
+			w.Write([]byte(`{"grades": []}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return fake, server
+}
+
+// fakeService keeps the messages it is asked to send, it fails while err is
+// set. onSend is called before a message is sent.
+type fakeService struct {
+	mu       sync.Mutex
+	messages []string
+	err      error
+	onSend   func()
+}
+
+func (s *fakeService) Send(ctx context.Context, msg string) error {
+	if s.onSend != nil {
+		s.onSend()
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	s.messages = append(s.messages, msg)
+
+	return nil
+}
+
+func (s *fakeService) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+func (s *fakeService) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.messages
+}
+
+func newTestConfig(t *testing.T, moodleURL string) config.Config {
+	dir := t.TempDir()
+
+	return config.Config{
+		Logger:               logging.Discard(),
+		UpdatesToCheck:       []string{"Grade"},
+		ToPrint:              []string{"Title"},
+		ToPrintOnUpdates:     []string{"Grade"},
+		MoodleToken:          "token",
+		CheckInterval:        time.Hour,
+		Workers:              1,
+		RequestsPerSecond:    1000,
+		MoodleURL:            moodleURL,
+		CourseClassification: moodle.ClassificationAll,
+		LastGradesPath:       filepath.Join(dir, "last_grades.json"),
+		GradesHistoryPath:    filepath.Join(dir, "grades_history.jsonl"),
+		LastTimeNotifyedPath: filepath.Join(dir, "last_time_notifyed_time"),
+	}
+}
+
+func newTestChecker(t *testing.T, cfg config.Config, service notifyer.Service) checker {
+	f := formatter.NewFormatter(formatter.FormatConfig{
+		UpdatesToCheck:   cfg.UpdatesToCheck,
+		ToPrintOnUpdates: cfg.ToPrintOnUpdates,
+		ToPrint:          cfg.ToPrint,
+	})
+	n := notifyer.NewNotifyer(service, "fake", f, cfg.LastTimeNotifyedPath, cfg.Logger)
+
+	schedule, err := scheduler.NewScheduler(scheduler.Config{CheckInterval: cfg.CheckInterval}, cfg.Logger)
+	assert.NoError(t, err)
+
+	return newChecker(cfg, &n, schedule, course.NewDigest(cfg.DigestPath, cfg.Logger), false)
+}
+
+func savedGrade(t *testing.T, cfg config.Config) string {
+	saved, err := course.NewGrades(course.SaveConfig{LastGradesPath: cfg.LastGradesPath}, cfg.Logger).GetSaved()
+	assert.NoError(t, err)
+	assert.Len(t, saved, 1)
+
+	for _, grade := range saved[0].Grades {
+		if strings.HasSuffix(grade.Title, "Lab 4") {
+			return grade.Grade
+		}
+	}
+
+	return ""
+}
+
+func TestCheckerNotifierFailure(t *testing.T) {
+	fake, server := newFakeMoodle(t)
+	cfg := newTestConfig(t, server.URL)
+	service := &fakeService{}
+	c := newTestChecker(t, cfg, service)
+
+	assert.NoError(t, c.check(context.Background()))
+	assert.Equal(t, "8", savedGrade(t, cfg))
+
+	t.Run("changes are kept until they are sent", func(t *testing.T) {
+		fake.grade.Store("9")
+		service.fail(errors.New("telegram is down"))
+
+		err := c.check(context.Background())
+
+		assert.Equal(t, failure.KindNotifier, failure.KindOf(err))
+		assert.Equal(t, "8", savedGrade(t, cfg))
+		assert.Empty(t, service.sent())
+
+		service.fail(nil)
+
+		assert.NoError(t, c.check(context.Background()))
+		assert.Equal(t, "9", savedGrade(t, cfg))
+		assert.Len(t, service.sent(), 1)
+		assert.Contains(t, service.sent()[0], `"8 / 10 (80 %)"  ->  "9 / 10 (90 %)"`)
+	})
+
+	t.Run("digest stays open until it is sent", func(t *testing.T) {
+		saved, err := course.NewGrades(course.SaveConfig{LastGradesPath: cfg.LastGradesPath}, cfg.Logger).GetSaved()
+		assert.NoError(t, err)
+		c.digest.Open(saved)
+		fake.grade.Store("10")
+		service.fail(errors.New("telegram is down"))
+
+		err = c.check(context.Background())
+
+		assert.Error(t, err)
+		assert.True(t, c.digest.IsOpen())
+
+		service.fail(nil)
+
+		assert.NoError(t, c.check(context.Background()))
+		assert.False(t, c.digest.IsOpen())
+		assert.Len(t, service.sent(), 2)
+		assert.Contains(t, service.sent()[1], `"9 / 10 (90 %)"  ->  "10 / 10 (100 %)"`)
+	})
+
+	t.Run("rejected message does not block the snapshot", func(t *testing.T) {
+		fake.grade.Store("7")
+		service.fail(fmt.Errorf("%w: Bad Request: message is too long", telegram.ErrRejected))
+
+		assert.NoError(t, c.check(context.Background()))
+		assert.Equal(t, "7", savedGrade(t, cfg))
+		assert.Len(t, service.sent(), 2)
+
+		service.fail(nil)
+	})
+}
+
+func TestCheckerCancelled(t *testing.T) {
+	fake, server := newFakeMoodle(t)
+	cfg := newTestConfig(t, server.URL)
+	service := &fakeService{}
+	c := newTestChecker(t, cfg, service)
+
+	assert.NoError(t, c.check(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service.onSend = cancel
+	fake.grade.Store("9")
+
+	err := c.check(ctx)
+
+	assert.NoError(t, err)
+	assert.Error(t, ctx.Err())
+	assert.Len(t, service.sent(), 1, "the updates are sent after shutdown was requested")
+	assert.Equal(t, "9", savedGrade(t, cfg), "the snapshot is saved after shutdown was requested")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+)
+
+// configCommand runs the config subcommands, only validate for now.
+func configCommand(args []string) error {
+	flags := newFlagSet("config")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: moodle-scrapper config validate [flags]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 || flags.Arg(0) != "validate" {
+		flags.Usage()
+		return fmt.Errorf("expected a config subcommand: validate")
+	}
+	flags.Parse(flags.Args()[1:])
+
+	_, err := config.LoadConfig(configPath, overrides)
+	if err != nil {
+		return fmt.Errorf("%s: %v", configPath, err)
+	}
+
+	fmt.Printf("%s is valid\n", configPath)
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/secret"
+)
+
+// credentialsCommand runs the credentials subcommands: set prompts for the
+// moodle login and password, encrypt rewrites the credentials file with
+// the key and key prints a new key.
+func credentialsCommand(args []string) error {
+	flags := newFlagSet("credentials")
+	login := flags.String("login", "", "moodle login, asked for if not set")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: moodle-scrapper credentials set|encrypt|key [flags]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("expected a credentials subcommand: set, encrypt or key")
+	}
+	subcommand := flags.Arg(0)
+	flags.Parse(flags.Args()[1:])
+
+	if subcommand == "key" {
+		key, err := secret.GenerateKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+	}
+
+	// the credentials are likely missing or wrong, so the other problems
+	// of the config do not stop them from being set
+	cfg, err := config.LoadConfig(configPath, overrides)
+	validationErr := config.ValidationError{}
+	if err != nil && !errors.As(err, &validationErr) {
+		return fmt.Errorf("%s: %v", configPath, err)
+	}
+	// a bad key must not be taken as no key, the credentials would be saved
+	// in plaintext
+	_, err = overrides.CredentialsKey()
+	if err != nil {
+		return err
+	}
+	credentials := cfg.MoodleCredentials()
+
+	switch subcommand {
+	case "set":
+		if *login == "" {
+			*login, err = prompt("Moodle login: ", true)
+			if err != nil {
+				return err
+			}
+		}
+		password, err := prompt("Moodle password: ", false)
+		if err != nil {
+			return err
+		}
+
+		err = credentials.Set(*login, password)
+		if err != nil {
+			return err
+		}
+	case "encrypt":
+		if !cfg.CredentialsKey.IsSet() {
+			return fmt.Errorf("set %s or %s to encrypt the credentials", config.EnvCredentialsKey, config.EnvCredentialsPassphrase)
+		}
+
+		err = credentials.Rewrite()
+		if err != nil {
+			return err
+		}
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown credentials subcommand %q", subcommand)
+	}
+
+	if cfg.CredentialsKey.IsSet() {
+		fmt.Printf("saved encrypted credentials to %s\n", cfg.MoodleCredentialsPath)
+	} else {
+		fmt.Printf("saved credentials to %s, set %s or %s to encrypt them\n", cfg.MoodleCredentialsPath, config.EnvCredentialsKey, config.EnvCredentialsPassphrase)
+	}
+
+	return nil
+}
+
+// stdin is shared by the prompts, piped input may be read ahead.
+var stdin = bufio.NewReader(os.Stdin)
+
+// prompt reads a line from the terminal, without showing it unless echo is
+// set. Piped input is read a line per prompt.
+func prompt(msg string, echo bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+	isTerminal := term.IsTerminal(fd)
+	if isTerminal {
+		fmt.Fprint(os.Stderr, msg)
+	}
+
+	if !isTerminal || echo {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read %s%v", strings.ToLower(msg), err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s%v", strings.ToLower(msg), err)
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/formatter"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/report"
+)
+
+// diffSnapshots prints the changes between two snapshot files, or between
+// the grades at two times rebuilt from the history, without moodle.
+func diffSnapshots(args []string) error {
+	flags := newFlagSet("diff")
+	fromFlag := flags.String("from", "", "compare the grades at this time, instead of snapshot files")
+	toFlag := flags.String("to", "", "compare up to the grades at this time, the last snapshot by default")
+	formatName := flags.String("format", "text", "output format: text, as it would be sent, or json with every change")
+	courseQuery := flags.String("course", "", "course ID or a regular expression matched against the course name")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: moodle-scrapper diff [flags] <from.json> <to.json>\n")
+		fmt.Fprintf(flags.Output(), "       moodle-scrapper diff [flags] --from <time> [--to <time>]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *formatName != "text" && *formatName != "json" {
+		return fmt.Errorf("unknown format %q, expected text or json", *formatName)
+	}
+
+	cfg, err := config.LoadConfig(configPath, overrides)
+	if err != nil {
+		return fmt.Errorf("failed to get configuration: %v", err)
+	}
+	// stdout is for the changes
+	cfg.SetLogOutput(os.Stderr)
+
+	from, to, err := loadSnapshots(cfg, flags.Args(), *fromFlag, *toFlag)
+	if err != nil {
+		return err
+	}
+
+	from, err = report.FilterCourses(from, *courseQuery)
+	if err != nil {
+		return err
+	}
+	to, err = report.FilterCourses(to, *courseQuery)
+	if err != nil {
+		return err
+	}
+
+	changes := course.Diff(from, to, cfg.Logger)
+
+	if *formatName == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(changes)
+		if err != nil {
+			return fmt.Errorf("failed to write changes: %v", err)
+		}
+
+		return nil
+	}
+
+	notifications := notifyer.NewPrintNotifyer(cfg, os.Stdout)
+
+	sent, err := notifications.SendUpdates(context.Background(), formatter.ConvertCourseGradesChange(changes))
+	if err != nil {
+		return err
+	}
+
+	if sent == 0 && len(changes) > 0 {
+		cfg.Logger.Info("courses changed, but the config filters out all of the changes", "changed_courses", len(changes))
+	}
+	if len(changes) == 0 {
+		cfg.Logger.Info("no changes")
+	}
+
+	return nil
+}
+
+func loadSnapshots(
+	cfg config.Config,
+	paths []string,
+	fromFlag, toFlag string,
+) ([]moodle.Course, []moodle.Course, error) {
+	if len(paths) == 2 {
+		from, err := course.ReadSnapshot(paths[0])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		to, err := course.ReadSnapshot(paths[1])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return from, to, nil
+	}
+
+	if len(paths) != 0 || fromFlag == "" {
+		return nil, nil, fmt.Errorf("expected two snapshot files or --from")
+	}
+
+	fromTime, err := parseTime(fromFlag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toTime := time.Now()
+	if toFlag != "" {
+		toTime, err = parseTime(toFlag)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	grades := course.NewGrades(course.SaveConfig{
+		LastGradesPath:    cfg.LastGradesPath,
+		GradesHistoryPath: cfg.GradesHistoryPath,
+	}, cfg.Logger)
+
+	from, err := grades.SnapshotAt(fromTime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	to, err := grades.SnapshotAt(toTime)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/export"
+)
+
+// exportGrades writes the last snapshot, or the history with "history", for
+// spreadsheets.
+func exportGrades(args []string) error {
+	flags := newFlagSet("export")
+	formatName := flags.String("format", string(export.FormatCSV), "output format: csv, jsonl or xlsx")
+	outputPath := flags.String("output", "", "file to write to instead of stdout")
+	fromFlag := flags.String("from", "", "export history since this time")
+	toFlag := flags.String("to", "", "export history before this time")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: moodle-scrapper export [snapshot|history] [flags]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	subject := "snapshot"
+	if flags.NArg() > 0 {
+		subject = flags.Arg(0)
+		flags.Parse(flags.Args()[1:])
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(configPath, overrides)
+	if err != nil {
+		return fmt.Errorf("failed to get configuration: %v", err)
+	}
+	cfg.SetLogOutput(os.Stderr)
+
+	grades := course.NewGrades(course.SaveConfig{
+		LastGradesPath:    cfg.LastGradesPath,
+		GradesHistoryPath: cfg.GradesHistoryPath,
+	}, cfg.Logger)
+
+	var table export.Table
+	switch subject {
+	case "snapshot":
+		snapshot, err := grades.GetSaved()
+		if err != nil {
+			return err
+		}
+		table = export.SnapshotTable(snapshot)
+
+	case "history":
+		from, err := parseTime(*fromFlag)
+		if err != nil {
+			return err
+		}
+		to, err := parseTime(*toFlag)
+		if err != nil {
+			return err
+		}
+
+		history, err := grades.GetHistory(from, to)
+		if err != nil {
+			return err
+		}
+		table = export.HistoryTable(history)
+
+	default:
+		return fmt.Errorf("unknown export %q, expected snapshot or history", subject)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outputPath != "" {
+		f, err := os.Create(*outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to export: %v", err)
+		}
+		defer f.Close()
+
+		out = f
+	}
+
+	return export.Write(out, table, format)
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTime reads a time flag as RFC 3339 or a local date with an optional
+// time, an empty flag is the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("bad time %q, expected e.g. 2023-05-19 or 2023-05-19 15:04", value)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/report"
+)
+
+// showGrades prints grades of the last snapshot, or fetched from moodle with
+// --live, without saving them.
+func showGrades(args []string) error {
+	flags := newFlagSet("grades")
+	courseQuery := flags.String("course", "", "course ID or a regular expression matched against the course name")
+	formatName := flags.String("format", string(report.FormatTable), "output format: table, json, csv or markdown")
+	isLive := flags.Bool("live", false, "fetch grades from moodle instead of the last snapshot")
+	flags.Parse(args)
+
+	format, err := report.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(configPath, overrides)
+	if err != nil {
+		return fmt.Errorf("failed to get configuration: %v", err)
+	}
+	// stdout is for the report
+	cfg.SetLogOutput(os.Stderr)
+
+	courses, err := loadGrades(cfg, *isLive)
+	if err != nil {
+		return err
+	}
+
+	courses, err = report.FilterCourses(courses, *courseQuery)
+	if err != nil {
+		return err
+	}
+
+	return report.Write(os.Stdout, courses, format)
+}
+
+func loadGrades(cfg config.Config, isLive bool) ([]moodle.Course, error) {
+	if !isLive {
+		grades := course.NewGrades(course.SaveConfig{LastGradesPath: cfg.LastGradesPath}, cfg.Logger)
+		return grades.GetSaved()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fetcher := newGradesFetcher(cfg)
+
+	courses, err := fetcher.fetch(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, warning := range moodle.CollectWarnings(courses) {
+		cfg.Logger.Warn("failed to parse grades", logging.KeyCourseID, warning.CourseID, logging.KeyCourse, warning.CourseName, "row", warning.RowID, logging.Err(warning.Err))
+	}
+
+	return courses, nil
+}
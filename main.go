@@ -1,80 +1,111 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
 
 	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
-	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
-	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
-	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer"
-	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/formatter"
-	"github.com/aDeepRecession/moodle-scrapper/pkg/terminal"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/redact"
 )
 
 var configPath string = "./config.json"
 
-func main() {
+// overrides are the config values set by flags, every command accepts them.
+var overrides = config.Overrides{}
 
-	cfg := config.GetConfigFromPath(configPath)
+type overrideFlag struct {
+	name      string
+	overrides config.Overrides
+}
 
-	notifyer := notifyer.NewTelegramNotifyer(cfg)
+func (f overrideFlag) String() string {
+	return ""
+}
 
-	output := terminal.NewTerminal(cfg)
+func (f overrideFlag) Set(value string) error {
+	f.overrides[f.name] = value
+	return nil
+}
 
-	saveCfg := course.SaveConfig{
-		LastGradesPath:    cfg.LastGradesPath,
-		GradesHistoryPath: cfg.GradesHistoryPath,
-	}
+var overrideFlags = []struct {
+	flag  string
+	name  string
+	usage string
+}{
+	{"moodle-login", config.EnvMoodleLogin, "moodle login"},
+	{"moodle-password", config.EnvMoodlePassword, "moodle password, prefer the environment for it"},
+	{"moodle-token", config.EnvMoodleToken, "moodle token, prefer the environment for it"},
+	{"telegram-bot-key", config.EnvTelegramBotKey, "telegram bot key, prefer the environment for it"},
+	{"telegram-chat-id", config.EnvTelegramChatID, "telegram chat ID"},
+	{"check-interval", config.EnvCheckInterval, "seconds or a duration like 15m between checks"},
+}
 
-	for {
-		token, err := moodle.GetTokens(cfg.MoodleCredentialsPath, cfg.Logger)
-		if err != nil {
-			output.PrintError(err)
-			output.WaitFailedRequestRepeatInterval()
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
 
-			continue
-		}
+var commands = []command{
+	{"run", "check grades on schedule until stopped (default)", runDaemon},
+	{"check", "run a single check and exit", checkOnce},
+	{"status", "show the last snapshot and pending notifications", showStatus},
+	{"grades", "print grades of the last snapshot or fetched live", showGrades},
+	{"export", "export the snapshot or the change history as csv, jsonl or xlsx", exportGrades},
+	{"replay", "send the stored changes between two times again", replayHistory},
+	{"diff", "compare two snapshot files or the grades at two times", diffSnapshots},
+	{"config", "validate the config: config validate", configCommand},
+	{"credentials", "set, encrypt the moodle credentials or make a key", credentialsCommand},
+}
 
-		output.PrintMsg("initializing moodleAPI...")
-		moodleAPI, err := moodle.NewMoodle(token, cfg.Logger)
-		if err != nil {
-			output.PrintError(err)
-			output.WaitFailedRequestRepeatInterval()
+func main() {
+	flags := newFlagSet("moodle-scrapper")
+	flags.Usage = printUsage
+	flags.Parse(os.Args[1:])
 
+	name, args := "run", flags.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
 			continue
 		}
 
-		output.PrintMsg("getting moodle grades...")
-		coursesGrades, err := moodleAPI.GetNonHiddenCourses()
+		err := cmd.run(args)
 		if err != nil {
-			output.PrintError(err)
-			output.WaitFailedRequestRepeatInterval()
-
-			continue
+			fmt.Fprintln(os.Stderr, redact.String(err.Error()))
+			os.Exit(1)
 		}
 
-		grades := course.NewGrades(saveCfg, cfg.Logger)
-
-		gradeChanges, err := grades.Compare(coursesGrades)
-		if err != nil {
-			output.PrintError(err)
-			output.WaitFailedRequestRepeatInterval()
+		return
+	}
 
-			continue
-		}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage()
+	os.Exit(2)
+}
 
-		output.PrintMsg(fmt.Sprintf("found %v changes\n", len(gradeChanges)))
+// newFlagSet makes flags of a command, --config and the overrides are
+// accepted by every command, before or after its name.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&configPath, "config", configPath, "path to the config file")
+	for _, override := range overrideFlags {
+		usage := fmt.Sprintf("%s, overrides $%s and $%s_FILE", override.usage, override.name, override.name)
+		flags.Var(overrideFlag{override.name, overrides}, override.flag, usage)
+	}
 
-		grades.Save(coursesGrades)
+	return flags
+}
 
-		messagesSended, err := notifyer.SendUpdates(
-			formatter.ConvertCourseGradesChange(gradeChanges),
-		)
-		if err != nil {
-			output.PrintError(err)
-		}
-		output.PrintMsg(fmt.Sprintf("sended %v messages\n", messagesSended))
+func printUsage() {
+	out := flag.CommandLine.Output()
 
-		output.WaitUntilNextCheck()
+	fmt.Fprintf(out, "usage: moodle-scrapper [--config file] <command> [flags]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-12s %s\n", cmd.name, cmd.usage)
 	}
 }
@@ -2,25 +2,64 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strconv"
 	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/dump"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/redact"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/secret"
 )
 
 type Config struct {
-	Logger                     *log.Logger
+	Logger                     *slog.Logger
+	LogLevel                   slog.Level
+	LogFormat                  logging.Format
+	Redactor                   redact.Redactor
 	UpdatesToCheck             []string
 	ToPrint                    []string
 	ToPrintOnUpdates           []string
+	IgnoreTotals               bool
+	SendOverview               bool
 	TelegramBotKey             string
 	TelegramChatID             int
+	MoodleLogin                string
+	MoodlePassword             string
+	MoodleToken                string
 	FailedRequestRepeatTimeout time.Duration
 	CheckInterval              time.Duration
+	BackoffMax                 time.Duration
+	AlertAfterFailures         int
+	Schedules                  []string
+	QuietHoursStart            string
+	QuietHoursEnd              string
+	TimeZone                   string
+	Workers                    int
+	RequestsPerSecond          float64
+	CourseTimeout              time.Duration
+	FullRefreshEvery           int
+	MoodleURL                  string
+	RequestTimeout             time.Duration
+	RequestRetries             int
+	CourseClassification       string
+	CourseGracePeriod          time.Duration
+	IncludeCourses             []string
+	ExcludeCourses             []string
+	DebugDumpDir               string
+	DebugDumpRetention         time.Duration
+	MetricsAddr                string
 	LastGradesPath             string
 	GradesHistoryPath          string
+	DigestPath                 string
 	MoodleCredentialsPath      string
+	CredentialsKey             secret.Key
 	TelegramCredentialsPath    string
 	LastTimeNotifyedPath       string
 }
@@ -30,108 +69,238 @@ type telegramCredentialsJSON struct {
 	TelegramChatID int    `json:"telegramChatID"`
 }
 
+// configJSON is the config file in any format, see decodeConfigFile.
 type configJSON struct {
+	LogLevel                   string   `json:"logLevel"`
+	LogFormat                  string   `json:"logFormat"`
+	Login                      string   `json:"login"`
+	Password                   string   `json:"password"`
+	Token                      string   `json:"token"`
+	TelegramBotKey             string   `json:"telegramBotKey"`
+	TelegramChatID             int      `json:"telegramChatID"`
 	UpdatesToCheck             []string `json:"updatesToCheck"`
 	ToPrint                    []string `json:"toPrint"`
 	ToPrintOnUpdates           []string `json:"toPrintOnUpdates"`
-	FailedRequestRepeatTimeout int      `json:"failedRequestRepeatTimeout"`
-	CheckInterval              int      `json:"checkInterval"`
+	IgnoreTotals               bool     `json:"ignoreTotals"`
+	SendOverview               bool     `json:"sendOverview"`
+	FailedRequestRepeatTimeout Duration `json:"failedRequestRepeatTimeout"`
+	CheckInterval              Duration `json:"checkInterval"`
+	BackoffMax                 Duration `json:"backoffMax"`
+	AlertAfterFailures         int      `json:"alertAfterFailures"`
+	Schedules                  []string `json:"schedules"`
+	QuietHoursStart            string   `json:"quietHoursStart"`
+	QuietHoursEnd              string   `json:"quietHoursEnd"`
+	TimeZone                   string   `json:"timeZone"`
+	Workers                    int      `json:"workers"`
+	RequestsPerSecond          float64  `json:"requestsPerSecond"`
+	CourseTimeout              Duration `json:"courseTimeout"`
+	FullRefreshEvery           int      `json:"fullRefreshEvery"`
+	MoodleURL                  string   `json:"moodleURL"`
+	RequestTimeout             Duration `json:"requestTimeout"`
+	RequestRetries             int      `json:"requestRetries"`
+	CourseClassification       string   `json:"courseClassification"`
+	CourseGracePeriod          Duration `json:"courseGracePeriod"`
+	IncludeCourses             []string `json:"includeCourses"`
+	ExcludeCourses             []string `json:"excludeCourses"`
+	DebugDumpDir               string   `json:"debugDumpDir"`
+	DebugDumpRetention         Duration `json:"debugDumpRetention"`
+	MetricsAddr                string   `json:"metricsAddr"`
 	LastGradesPath             string   `json:"lastGradesPath"`
 	GradesHistoryPath          string   `json:"gradesHistoryPath"`
+	DigestPath                 string   `json:"digestPath"`
 	MoodleCredentialsPath      string   `json:"moodleCredentialsPath"`
 	TelegramCredentialsPath    string   `json:"telegramCredentialsPath"`
 	LastTimeNotifyedPath       string   `json:"lastTimeNotifyedPath"`
 }
 
-var logger *log.Logger = log.New(os.Stdout, "", log.Ldate|log.Ltime|log.Lshortfile)
-
 func GetConfigFromPath(configPath string) Config {
-	f, err := os.OpenFile(configPath, os.O_RDONLY, 0644)
+	cfg, err := LoadConfig(configPath, nil)
 	if err != nil {
 		log.Printf("failed to get configuration: %v", err)
-		os.Exit(0)
+		os.Exit(1)
 	}
-	defer f.Close()
 
-	cfg, err := NewConfig(f)
+	return cfg
+}
+
+// LoadConfig reads the config like GetConfigFromPath, but returns the error
+// instead of exiting, e.g. to keep the running config if a reload fails.
+func LoadConfig(configPath string, overrides Overrides) (Config, error) {
+	f, err := os.OpenFile(configPath, os.O_RDONLY, 0644)
 	if err != nil {
-		log.Printf("failed to get configuration: %v", err)
-		os.Exit(0)
+		return Config{}, err
 	}
+	defer f.Close()
 
-	return cfg
+	return NewConfig(f, FormatOf(configPath), overrides)
 }
 
-func NewConfig(cfgReader io.Reader) (Config, error) {
-	cfgJSON, err := getConfigJSON(cfgReader)
+// NewConfig reads and validates the config, a ValidationError lists every
+// problem found and comes with the config as it was read. Credentials may
+// be set in the config itself, the credential files are used for the ones
+// that are not.
+func NewConfig(cfgReader io.Reader, format Format, overrides Overrides) (Config, error) {
+	cfgByte, err := io.ReadAll(cfgReader)
 	if err != nil {
-		return Config{}, err
+		return Config{}, fmt.Errorf("failed to get config: %v", err)
 	}
 
-	telegramCredentials, err := getTelegramCredentials(cfgJSON.TelegramCredentialsPath)
+	cfgJSON, problems, err := decodeConfigFile(cfgByte, format)
 	if err != nil {
 		return Config{}, err
 	}
 
+	telegramCredentials, err := getTelegramCredentials(
+		cfgJSON.TelegramCredentialsPath,
+		telegramCredentialsJSON{cfgJSON.TelegramBotKey, cfgJSON.TelegramChatID},
+		overrides,
+	)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
 	cfg := Config{
-		Logger:                     logger,
 		UpdatesToCheck:             cfgJSON.UpdatesToCheck,
 		ToPrint:                    cfgJSON.ToPrint,
 		ToPrintOnUpdates:           cfgJSON.ToPrintOnUpdates,
+		IgnoreTotals:               cfgJSON.IgnoreTotals,
+		SendOverview:               cfgJSON.SendOverview,
 		TelegramBotKey:             telegramCredentials.TelegramBotKey,
 		TelegramChatID:             telegramCredentials.TelegramChatID,
-		FailedRequestRepeatTimeout: time.Duration(cfgJSON.FailedRequestRepeatTimeout) * time.Second,
-		CheckInterval:              time.Duration(cfgJSON.CheckInterval) * time.Second,
+		MoodleLogin:                cfgJSON.Login,
+		MoodlePassword:             cfgJSON.Password,
+		MoodleToken:                cfgJSON.Token,
+		FailedRequestRepeatTimeout: time.Duration(cfgJSON.FailedRequestRepeatTimeout),
+		CheckInterval:              time.Duration(cfgJSON.CheckInterval),
+		BackoffMax:                 time.Duration(cfgJSON.BackoffMax),
+		AlertAfterFailures:         cfgJSON.AlertAfterFailures,
+		Schedules:                  cfgJSON.Schedules,
+		QuietHoursStart:            cfgJSON.QuietHoursStart,
+		QuietHoursEnd:              cfgJSON.QuietHoursEnd,
+		TimeZone:                   cfgJSON.TimeZone,
+		Workers:                    cfgJSON.Workers,
+		RequestsPerSecond:          cfgJSON.RequestsPerSecond,
+		CourseTimeout:              time.Duration(cfgJSON.CourseTimeout),
+		FullRefreshEvery:           cfgJSON.FullRefreshEvery,
+		MoodleURL:                  cfgJSON.MoodleURL,
+		RequestTimeout:             time.Duration(cfgJSON.RequestTimeout),
+		RequestRetries:             cfgJSON.RequestRetries,
+		CourseClassification:       cfgJSON.CourseClassification,
+		CourseGracePeriod:          time.Duration(cfgJSON.CourseGracePeriod),
+		IncludeCourses:             cfgJSON.IncludeCourses,
+		ExcludeCourses:             cfgJSON.ExcludeCourses,
+		DebugDumpDir:               cfgJSON.DebugDumpDir,
+		DebugDumpRetention:         time.Duration(cfgJSON.DebugDumpRetention),
+		MetricsAddr:                cfgJSON.MetricsAddr,
 		LastGradesPath:             cfgJSON.LastGradesPath,
 		GradesHistoryPath:          cfgJSON.GradesHistoryPath,
+		DigestPath:                 cfgJSON.DigestPath,
 		MoodleCredentialsPath:      cfgJSON.MoodleCredentialsPath,
-		TelegramCredentialsPath:    cfgJSON.MoodleCredentialsPath,
+		TelegramCredentialsPath:    cfgJSON.TelegramCredentialsPath,
 		LastTimeNotifyedPath:       cfgJSON.LastTimeNotifyedPath,
 	}
 
-	return cfg, nil
-}
-
-func getConfigJSON(cfgReader io.Reader) (configJSON, error) {
-	cfgJSON := configJSON{}
+	err = overrides.apply(&cfg)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
 
-	cfgByte, err := io.ReadAll(cfgReader)
+	cfg.CredentialsKey, err = overrides.CredentialsKey()
 	if err != nil {
-		return configJSON{}, fmt.Errorf("failed to get config: %v", err)
+		problems = append(problems, err.Error())
 	}
 
-	err = json.Unmarshal(cfgByte, &cfgJSON)
+	cfg.LogLevel, err = logging.ParseLevel(cfgJSON.LogLevel)
 	if err != nil {
-		return configJSON{}, fmt.Errorf("failed to get config: %v", err)
+		problems = append(problems, err.Error())
 	}
+	cfg.LogFormat, err = logging.ParseFormat(cfgJSON.LogFormat)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	// the credentials file is read for its secrets with a logger that
+	// redacts the ones known so far, its warnings hold none
+	cfg.Redactor = redact.New(cfg.MoodlePassword, cfg.MoodleToken, cfg.TelegramBotKey)
+	cfg.SetLogOutput(os.Stdout)
+	cfg.Redactor = redact.New(append(cfg.MoodleCredentials().Secrets(), cfg.TelegramBotKey)...)
+	cfg.SetLogOutput(os.Stdout)
+
+	problems = append(problems, cfg.problems()...)
+	if len(problems) > 0 {
+		return cfg, ValidationError{problems}
+	}
+
+	return cfg, nil
+}
 
-	return cfgJSON, nil
+// MoodleCredentials are the ones of the credentials file, the ones set in
+// the config or by the environment take precedence.
+func (cfg Config) MoodleCredentials() moodle.Credentials {
+	return moodle.NewCredentials(cfg.MoodleCredentialsPath, cfg.CredentialsKey, moodle.CredentialsData{
+		Login:    cfg.MoodleLogin,
+		Password: cfg.MoodlePassword,
+		Token:    cfg.MoodleToken,
+	}, cfg.Logger)
 }
 
-func getTelegramCredentials(credentialsPath string) (telegramCredentialsJSON, error) {
-	credentialsFile, err := os.OpenFile(credentialsPath, os.O_RDONLY, 0644)
-	if err != nil {
-		return telegramCredentialsJSON{}, fmt.Errorf("failed to get config: %v", err)
+// SetLogOutput makes a new logger that writes to w, secrets are still
+// redacted. It is meant to be called before the logger is passed on.
+func (cfg *Config) SetLogOutput(w io.Writer) {
+	cfg.Logger = logging.New(cfg.Redactor.Writer(w), cfg.LogFormat, cfg.LogLevel)
+}
+
+// Dumps keep the responses that could not be understood in DebugDumpDir.
+func (cfg Config) Dumps() dump.Dumps {
+	return dump.New(cfg.DebugDumpDir, cfg.DebugDumpRetention, cfg.Redactor, cfg.Logger)
+}
+
+// getTelegramCredentials reads the credentials file, the ones set in the
+// config and then by the environment take precedence. The file may be
+// missing if both of them are set elsewhere.
+func getTelegramCredentials(
+	credentialsPath string,
+	inline telegramCredentialsJSON,
+	overrides Overrides,
+) (telegramCredentialsJSON, error) {
+	credentials := telegramCredentialsJSON{}
+
+	credentialsByte, err := os.ReadFile(credentialsPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return telegramCredentialsJSON{}, fmt.Errorf("failed to read telegram credentials: %v", err)
 	}
-	defer credentialsFile.Close()
 
-	credentialsByte, err := io.ReadAll(credentialsFile)
-	if err != nil {
-		return telegramCredentialsJSON{}, fmt.Errorf("failed to get config: %v", err)
+	if err == nil {
+		err = json.Unmarshal(credentialsByte, &credentials)
+		if err != nil {
+			return telegramCredentialsJSON{}, fmt.Errorf("failed to read telegram credentials: %v", err)
+		}
 	}
 
-	credentials := telegramCredentialsJSON{}
-	err = json.Unmarshal(credentialsByte, &credentials)
-	if err != nil {
-		return telegramCredentialsJSON{}, fmt.Errorf("failed to get config: %v", err)
+	if inline.TelegramBotKey != "" {
+		credentials.TelegramBotKey = inline.TelegramBotKey
+	}
+	if inline.TelegramChatID != 0 {
+		credentials.TelegramChatID = inline.TelegramChatID
 	}
 
-	if credentials.TelegramBotKey == "" {
-		return telegramCredentialsJSON{}, fmt.Errorf("telegram bot key is empty")
+	botKey, err := overrides.lookup(EnvTelegramBotKey)
+	if err != nil {
+		return telegramCredentialsJSON{}, err
+	}
+	if botKey != "" {
+		credentials.TelegramBotKey = botKey
 	}
 
-	if credentials.TelegramChatID == 0 {
-		return telegramCredentialsJSON{}, fmt.Errorf("telegram chatID is empty")
+	chatID, err := overrides.lookup(EnvTelegramChatID)
+	if err != nil {
+		return telegramCredentialsJSON{}, err
+	}
+	if chatID != "" {
+		credentials.TelegramChatID, err = strconv.Atoi(chatID)
+		if err != nil {
+			return telegramCredentialsJSON{}, fmt.Errorf("bad %s: %v", EnvTelegramChatID, err)
+		}
 	}
 
 	return credentials, nil
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatTOML Format = "toml"
+)
+
+// FormatOf chooses the format by the extension of the config path, JSON is
+// the default.
+func FormatOf(configPath string) Format {
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatJSON
+	}
+}
+
+// configSections group the keys of a unified config file, e.g. moodle
+// credentials under "moodle". They are only for readability, a key means
+// the same in a section and at the top level.
+var configSections = []string{"moodle", "notifier", "scheduling"}
+
+// Duration is a number of seconds or a string like "1h30m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	err := json.Unmarshal(data, &seconds)
+	if err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var durationStr string
+	err = json.Unmarshal(data, &durationStr)
+	if err != nil {
+		return fmt.Errorf("bad duration %s, expected seconds or a string like \"1h30m\"", data)
+	}
+
+	duration, err := parseSeconds(durationStr)
+	if err != nil {
+		return fmt.Errorf("bad duration %q, expected seconds or a string like \"1h30m\"", durationStr)
+	}
+	*d = Duration(duration)
+
+	return nil
+}
+
+// decodeConfigFile decodes the config in any format to configJSON, it
+// returns the problems that do not stop decoding, like unknown keys.
+func decodeConfigFile(data []byte, format Format) (configJSON, []string, error) {
+	values := map[string]interface{}{}
+
+	var err error
+	switch format {
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &values)
+	case FormatTOML:
+		err = toml.Unmarshal(data, &values)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&values)
+	}
+	if err != nil {
+		return configJSON{}, nil, fmt.Errorf("failed to get config: %v", err)
+	}
+
+	problems := flattenSections(values)
+	problems = append(problems, unknownKeys(values)...)
+
+	flatData, err := json.Marshal(values)
+	if err != nil {
+		return configJSON{}, nil, fmt.Errorf("failed to get config: %v", err)
+	}
+
+	cfgJSON := configJSON{}
+	err = json.Unmarshal(flatData, &cfgJSON)
+	if err != nil {
+		return configJSON{}, nil, fmt.Errorf("failed to get config: %v", err)
+	}
+
+	return cfgJSON, problems, nil
+}
+
+// flattenSections moves the keys of the sections to the top level.
+func flattenSections(values map[string]interface{}) []string {
+	problems := []string{}
+
+	for _, section := range configSections {
+		sectionValue, ok := values[section]
+		if !ok {
+			continue
+		}
+		delete(values, section)
+
+		sectionValues, ok := sectionValue.(map[string]interface{})
+		if !ok {
+			problems = append(problems, fmt.Sprintf("section %q is not a table of keys", section))
+			continue
+		}
+
+		keys := make([]string, 0, len(sectionValues))
+		for key := range sectionValues {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			_, isSet := values[key]
+			if isSet {
+				problems = append(problems, fmt.Sprintf("key %q of section %q is set twice", key, section))
+				continue
+			}
+
+			values[key] = sectionValues[key]
+		}
+	}
+
+	return problems
+}
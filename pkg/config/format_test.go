@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatOf(t *testing.T) {
+	assert.Equal(t, FormatJSON, FormatOf("./config.json"))
+	assert.Equal(t, FormatYAML, FormatOf("/etc/moodle/config.yaml"))
+	assert.Equal(t, FormatYAML, FormatOf("config.YML"))
+	assert.Equal(t, FormatTOML, FormatOf("config.toml"))
+	assert.Equal(t, FormatJSON, FormatOf("config"))
+}
+
+func TestConfigFormats(t *testing.T) {
+	dir := t.TempDir()
+	paths := func(format string) string {
+		return strings.NewReplacer("DIR", dir).Replace(format)
+	}
+
+	yamlConfig := paths(`
+# everything in one file
+moodle:
+  login: student
+  password: secret
+  requestTimeout: 15s
+notifier:
+  telegramBotKey: key
+  telegramChatID: -100123456789
+  toPrint: [Title]
+scheduling:
+  checkInterval: 1h30m
+  quietHoursStart: "23:00"
+  quietHoursEnd: "08:00"
+lastGradesPath: DIR/last_grades.json
+gradesHistoryPath: DIR/grades_history.jsonl
+lastTimeNotifyedPath: DIR/last_time_notifyed_time
+`)
+
+	tomlConfig := paths(`
+# everything in one file
+lastGradesPath = "DIR/last_grades.json"
+gradesHistoryPath = "DIR/grades_history.jsonl"
+lastTimeNotifyedPath = "DIR/last_time_notifyed_time"
+
+[moodle]
+login = "student"
+password = "secret"
+requestTimeout = 15
+
+[notifier]
+telegramBotKey = "key"
+telegramChatID = -100123456789
+toPrint = ["Title"]
+
+[scheduling]
+checkInterval = "1h30m"
+quietHoursStart = "23:00"
+quietHoursEnd = "08:00"
+`)
+
+	for _, test := range []struct {
+		format Format
+		config string
+	}{
+		{FormatYAML, yamlConfig},
+		{FormatTOML, tomlConfig},
+	} {
+		t.Run(string(test.format), func(t *testing.T) {
+			cfg, err := NewConfig(strings.NewReader(test.config), test.format, nil)
+
+			assert.NoError(t, err)
+			assert.Equal(t, "student", cfg.MoodleLogin)
+			assert.Equal(t, "secret", cfg.MoodlePassword)
+			assert.Equal(t, "key", cfg.TelegramBotKey)
+			assert.Equal(t, -100123456789, cfg.TelegramChatID)
+			assert.Equal(t, []string{"Title"}, cfg.ToPrint)
+			assert.Equal(t, 90*time.Minute, cfg.CheckInterval)
+			assert.Equal(t, 15*time.Second, cfg.RequestTimeout)
+			assert.Equal(t, "23:00", cfg.QuietHoursStart)
+		})
+	}
+
+	t.Run("json durations", func(t *testing.T) {
+		cfgJSON := testConfigJSON(t, dir, `
+			"telegramBotKey": "key",
+			"telegramChatID": 1,
+			"checkInterval": 3600,
+			"backoffMax": "2h",
+			"courseGracePeriod": "720h"`)
+
+		cfg, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, time.Hour, cfg.CheckInterval)
+		assert.Equal(t, 2*time.Hour, cfg.BackoffMax)
+		assert.Equal(t, 30*24*time.Hour, cfg.CourseGracePeriod)
+	})
+
+	t.Run("bad duration", func(t *testing.T) {
+		_, err := NewConfig(strings.NewReader(`checkInterval: often`), FormatYAML, nil)
+
+		assert.EqualError(t, err, `failed to get config: bad duration "often", expected seconds or a string like "1h30m"`)
+	})
+
+	t.Run("key set twice", func(t *testing.T) {
+		config := yamlConfig + "checkInterval: 60\n"
+
+		_, err := NewConfig(strings.NewReader(config), FormatYAML, nil)
+
+		validationErr, ok := err.(ValidationError)
+		assert.True(t, ok)
+		assert.Equal(t, []string{`key "checkInterval" of section "scheduling" is set twice`}, validationErr.Problems)
+	})
+
+	t.Run("credential files are used for the rest", func(t *testing.T) {
+		config := strings.Replace(yamlConfig, "  telegramChatID: -100123456789\n", "", 1)
+		config += "telegramCredentialsPath: " + filepath.Join(dir, "telegram-credentials.json") + "\n"
+		err := os.WriteFile(filepath.Join(dir, "telegram-credentials.json"), []byte(`{"telegramBotKey": "file key", "telegramChatID": 7}`), 0600)
+		assert.NoError(t, err)
+
+		cfg, err := NewConfig(strings.NewReader(config), FormatYAML, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "key", cfg.TelegramBotKey)
+		assert.Equal(t, 7, cfg.TelegramChatID)
+	})
+}
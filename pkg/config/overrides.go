@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/secret"
+)
+
+const (
+	EnvMoodleLogin    = "MOODLE_LOGIN"
+	EnvMoodlePassword = "MOODLE_PASSWORD"
+	EnvMoodleToken    = "MOODLE_TOKEN"
+	EnvTelegramBotKey = "TELEGRAM_BOT_KEY"
+	EnvTelegramChatID = "TELEGRAM_CHAT_ID"
+	EnvCheckInterval  = "CHECK_INTERVAL"
+
+	// the moodle credentials file is encrypted with the key or the
+	// passphrase, CREDENTIALS_PASSPHRASE_FILE names a passphrase file
+	EnvCredentialsKey        = "CREDENTIALS_KEY"
+	EnvCredentialsPassphrase = "CREDENTIALS_PASSPHRASE"
+)
+
+// Overrides are values given on the command line by the name of the
+// environment variable they stand for. They take precedence over the
+// environment, which takes precedence over the config and credential files.
+type Overrides map[string]string
+
+// lookup returns the value of the flag, the environment variable name or
+// the file named by name_FILE, e.g. a docker secret. An empty value is
+// not set.
+func (overrides Overrides) lookup(name string) (string, error) {
+	value := overrides[name]
+	if value != "" {
+		return value, nil
+	}
+
+	value = os.Getenv(name)
+	valuePath := os.Getenv(name + "_FILE")
+	if value != "" && valuePath != "" {
+		return "", fmt.Errorf("both %s and %s_FILE are set", name, name)
+	}
+
+	if valuePath == "" {
+		return value, nil
+	}
+
+	valueBytes, err := os.ReadFile(valuePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s_FILE: %v", name, err)
+	}
+
+	return strings.TrimRight(string(valueBytes), "\r\n"), nil
+}
+
+// apply sets the overridden values of cfg, the values that are not
+// overridden are kept.
+func (overrides Overrides) apply(cfg *Config) error {
+	values := []struct {
+		name  string
+		value *string
+	}{
+		{EnvMoodleLogin, &cfg.MoodleLogin},
+		{EnvMoodlePassword, &cfg.MoodlePassword},
+		{EnvMoodleToken, &cfg.MoodleToken},
+	}
+	for _, value := range values {
+		override, err := overrides.lookup(value.name)
+		if err != nil {
+			return err
+		}
+		if override != "" {
+			*value.value = override
+		}
+	}
+
+	checkInterval, err := overrides.lookup(EnvCheckInterval)
+	if err != nil {
+		return err
+	}
+	if checkInterval != "" {
+		cfg.CheckInterval, err = parseSeconds(checkInterval)
+		if err != nil {
+			return fmt.Errorf("bad %s: %v", EnvCheckInterval, err)
+		}
+	}
+
+	return nil
+}
+
+// CredentialsKey returns the key of the credentials file, it is not set if
+// the file is kept in plaintext.
+func (overrides Overrides) CredentialsKey() (secret.Key, error) {
+	encodedKey, err := overrides.lookup(EnvCredentialsKey)
+	if err != nil {
+		return secret.Key{}, err
+	}
+	passphrase, err := overrides.lookup(EnvCredentialsPassphrase)
+	if err != nil {
+		return secret.Key{}, err
+	}
+
+	switch {
+	case encodedKey != "" && passphrase != "":
+		return secret.Key{}, fmt.Errorf("both %s and %s are set", EnvCredentialsKey, EnvCredentialsPassphrase)
+	case encodedKey != "":
+		key, err := secret.ParseKey(encodedKey)
+		if err != nil {
+			return secret.Key{}, fmt.Errorf("bad %s: %v", EnvCredentialsKey, err)
+		}
+		return key, nil
+	case passphrase != "":
+		return secret.NewPassphraseKey(passphrase), nil
+	default:
+		return secret.Key{}, nil
+	}
+}
+
+// parseSeconds reads a number of seconds, like the config file, or a
+// duration such as "15m".
+func parseSeconds(value string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/secret"
+)
+
+func TestOverrides(t *testing.T) {
+	dir := t.TempDir()
+	credentialsPath := filepath.Join(dir, "telegram-credentials.json")
+	err := os.WriteFile(credentialsPath, []byte(`{"telegramBotKey": "file key", "telegramChatID": 1}`), 0600)
+	assert.NoError(t, err)
+
+	newConfig := func(credentialsPath string, overrides Overrides) (Config, error) {
+		cfgJSON := testConfigJSON(t, dir, `"checkInterval": 3600, "telegramCredentialsPath": "`+credentialsPath+`"`)
+		return NewConfig(strings.NewReader(cfgJSON), FormatJSON, overrides)
+	}
+
+	t.Run("file values", func(t *testing.T) {
+		cfg, err := newConfig(credentialsPath, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "file key", cfg.TelegramBotKey)
+		assert.Equal(t, time.Hour, cfg.CheckInterval)
+	})
+
+	t.Run("environment over file", func(t *testing.T) {
+		t.Setenv(EnvTelegramBotKey, "env key")
+		t.Setenv(EnvCheckInterval, "15m")
+
+		cfg, err := newConfig(credentialsPath, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "env key", cfg.TelegramBotKey)
+		assert.Equal(t, 1, cfg.TelegramChatID)
+		assert.Equal(t, 15*time.Minute, cfg.CheckInterval)
+	})
+
+	t.Run("flag over environment", func(t *testing.T) {
+		t.Setenv(EnvMoodleLogin, "env login")
+		t.Setenv(EnvCheckInterval, "60")
+
+		cfg, err := newConfig(credentialsPath, Overrides{EnvMoodleLogin: "flag login"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "flag login", cfg.MoodleLogin)
+		assert.Equal(t, time.Minute, cfg.CheckInterval)
+	})
+
+	t.Run("secret files without credential files", func(t *testing.T) {
+		keyPath := filepath.Join(dir, "bot_key")
+		assert.NoError(t, os.WriteFile(keyPath, []byte("secret key\n"), 0600))
+		t.Setenv(EnvTelegramBotKey+"_FILE", keyPath)
+		t.Setenv(EnvTelegramChatID, "42")
+
+		cfg, err := newConfig(filepath.Join(dir, "missing.json"), nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "secret key", cfg.TelegramBotKey)
+		assert.Equal(t, 42, cfg.TelegramChatID)
+	})
+
+	t.Run("value and file both set", func(t *testing.T) {
+		t.Setenv(EnvMoodleToken, "token")
+		t.Setenv(EnvMoodleToken+"_FILE", filepath.Join(dir, "token"))
+
+		_, err := newConfig(credentialsPath, nil)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("bad chat ID", func(t *testing.T) {
+		t.Setenv(EnvTelegramChatID, "chat")
+
+		_, err := newConfig(credentialsPath, nil)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestCredentialsKey(t *testing.T) {
+	dir := t.TempDir()
+	cfgJSON := testConfigJSON(t, dir, `"checkInterval": 3600, "telegramBotKey": "key", "telegramChatID": 1`)
+
+	passphrasePath := filepath.Join(dir, "passphrase")
+	assert.NoError(t, os.WriteFile(passphrasePath, []byte("passphrase\n"), 0600))
+	err := secret.WriteFile(
+		filepath.Join(dir, "moodle-credentials.json"),
+		[]byte(`{"token": "token"}`),
+		secret.NewPassphraseKey("passphrase"),
+	)
+	assert.NoError(t, err)
+
+	t.Run("passphrase file", func(t *testing.T) {
+		t.Setenv(EnvCredentialsPassphrase+"_FILE", passphrasePath)
+
+		cfg, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		assert.NoError(t, err)
+		assert.True(t, cfg.CredentialsKey.IsSet())
+	})
+
+	t.Run("encrypted without a key", func(t *testing.T) {
+		_, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), secret.ErrNoKey.Error())
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		t.Setenv(EnvCredentialsPassphrase, "wrong")
+
+		_, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("key and passphrase both set", func(t *testing.T) {
+		key, err := secret.GenerateKey()
+		assert.NoError(t, err)
+		t.Setenv(EnvCredentialsKey, key)
+		t.Setenv(EnvCredentialsPassphrase, "passphrase")
+
+		_, err = NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		assert.Error(t, err)
+	})
+}
+
+// testConfigJSON returns a valid config with files in dir, fields replace
+// the defaults.
+func testConfigJSON(t *testing.T, dir string, fields string) string {
+	moodleCredentialsPath := filepath.Join(dir, "moodle-credentials.json")
+	err := os.WriteFile(moodleCredentialsPath, []byte(`{"token": "token"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return `{
+		"lastGradesPath": "` + filepath.Join(dir, "last_grades.json") + `",
+		"gradesHistoryPath": "` + filepath.Join(dir, "grades_history.jsonl") + `",
+		"lastTimeNotifyedPath": "` + filepath.Join(dir, "last_time_notifyed_time") + `",
+		"moodleCredentialsPath": "` + moodleCredentialsPath + `",
+		` + fields + `
+	}`
+}
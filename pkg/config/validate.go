@@ -0,0 +1,224 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/scheduler"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/secret"
+)
+
+// ValidationError lists every problem of a config, so all of them can be
+// fixed at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the config like NewConfig does, e.g. after changing it in
+// code.
+func (cfg Config) Validate() error {
+	problems := cfg.problems()
+	if len(problems) > 0 {
+		return ValidationError{problems}
+	}
+
+	return nil
+}
+
+func (cfg Config) problems() []string {
+	problems := []string{}
+
+	problems = append(problems, checkGradeFields("updatesToCheck", cfg.UpdatesToCheck)...)
+	problems = append(problems, checkGradeFields("toPrint", cfg.ToPrint)...)
+	problems = append(problems, checkGradeFields("toPrintOnUpdates", cfg.ToPrintOnUpdates)...)
+
+	// a zero timeout is not rejected, it is what a config without the key
+	// gets and means the default
+	numbers := []struct {
+		key         string
+		value       float64
+		zeroDefault bool
+	}{
+		{"failedRequestRepeatTimeout", cfg.FailedRequestRepeatTimeout.Seconds(), true},
+		{"checkInterval", cfg.CheckInterval.Seconds(), false},
+		{"backoffMax", cfg.BackoffMax.Seconds(), false},
+		{"alertAfterFailures", float64(cfg.AlertAfterFailures), false},
+		{"workers", float64(cfg.Workers), false},
+		{"requestsPerSecond", cfg.RequestsPerSecond, false},
+		{"courseTimeout", cfg.CourseTimeout.Seconds(), true},
+		{"fullRefreshEvery", float64(cfg.FullRefreshEvery), false},
+		{"requestTimeout", cfg.RequestTimeout.Seconds(), true},
+		{"requestRetries", float64(cfg.RequestRetries), false},
+		{"courseGracePeriod", cfg.CourseGracePeriod.Seconds(), false},
+		{"debugDumpRetention", cfg.DebugDumpRetention.Seconds(), false},
+	}
+	for _, number := range numbers {
+		if number.value >= 0 {
+			continue
+		}
+
+		if number.zeroDefault {
+			problems = append(problems, fmt.Sprintf("%s must not be negative, 0 means the default", number.key))
+		} else {
+			problems = append(problems, fmt.Sprintf("%s must not be negative", number.key))
+		}
+	}
+
+	scheduleCfg := scheduler.Config{
+		Schedules:       cfg.Schedules,
+		CheckInterval:   cfg.CheckInterval,
+		QuietHoursStart: cfg.QuietHoursStart,
+		QuietHoursEnd:   cfg.QuietHoursEnd,
+		TimeZone:        cfg.TimeZone,
+	}
+	for _, err := range scheduleCfg.Validate() {
+		problems = append(problems, err.Error())
+	}
+
+	courseFilter := moodle.CourseFilter{
+		Classification: cfg.CourseClassification,
+		Include:        cfg.IncludeCourses,
+		Exclude:        cfg.ExcludeCourses,
+	}
+	for _, err := range courseFilter.Validate() {
+		problems = append(problems, err.Error())
+	}
+
+	if cfg.MoodleURL != "" {
+		moodleURL, err := url.Parse(cfg.MoodleURL)
+		isHTTP := err == nil && (moodleURL.Scheme == "http" || moodleURL.Scheme == "https") && moodleURL.Host != ""
+		if !isHTTP {
+			problems = append(problems, fmt.Sprintf("moodleURL %q is not an http(s) URL", cfg.MoodleURL))
+		}
+	}
+
+	paths := []struct {
+		key        string
+		path       string
+		isRequired bool
+	}{
+		{"lastGradesPath", cfg.LastGradesPath, true},
+		{"gradesHistoryPath", cfg.GradesHistoryPath, true},
+		{"lastTimeNotifyedPath", cfg.LastTimeNotifyedPath, true},
+		{"digestPath", cfg.DigestPath, false},
+		{"debugDumpDir", cfg.DebugDumpDir, false},
+		{"moodleCredentialsPath", cfg.MoodleCredentialsPath, false},
+		{"telegramCredentialsPath", cfg.TelegramCredentialsPath, false},
+	}
+	for _, path := range paths {
+		problem := checkPath(path.key, path.path, path.isRequired)
+		if problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	if cfg.MetricsAddr != "" {
+		_, _, err := net.SplitHostPort(cfg.MetricsAddr)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("metricsAddr must be like \":9090\" or \"127.0.0.1:9090\": %v", err))
+		}
+	}
+
+	err := cfg.MoodleCredentials().Validate()
+	if errors.Is(err, secret.ErrNoKey) {
+		err = fmt.Errorf("%v, set %s or %s", err, EnvCredentialsKey, EnvCredentialsPassphrase)
+	}
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if cfg.TelegramBotKey == "" {
+		problems = append(problems, fmt.Sprintf("telegram bot key is empty, set it in %q or %s", cfg.TelegramCredentialsPath, EnvTelegramBotKey))
+	}
+	if cfg.TelegramChatID == 0 {
+		problems = append(problems, fmt.Sprintf("telegram chat ID is empty, set it in %q or %s", cfg.TelegramCredentialsPath, EnvTelegramChatID))
+	}
+
+	return problems
+}
+
+// checkGradeFields reports the names that are not moodle.GradeReport fields,
+// they would only fail when a message is sent.
+func checkGradeFields(key string, names []string) []string {
+	fieldNames := []string{}
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(moodle.GradeReport{})) {
+		fieldNames = append(fieldNames, field.Name)
+	}
+
+	problems := []string{}
+	for _, name := range names {
+		isField := false
+		for _, fieldName := range fieldNames {
+			isField = isField || name == fieldName
+		}
+
+		if !isField {
+			problems = append(problems, fmt.Sprintf(
+				"%s: unknown field %q, expected one of %s",
+				key,
+				name,
+				strings.Join(fieldNames, ", "),
+			))
+		}
+	}
+
+	return problems
+}
+
+// checkPath reports a missing required path or a path in a directory that
+// does not exist.
+func checkPath(key, path string, isRequired bool) string {
+	if path == "" {
+		if isRequired {
+			return fmt.Sprintf("%s is empty", key)
+		}
+		return ""
+	}
+
+	dir, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		return fmt.Sprintf("%s %q: %v", key, path, err)
+	}
+	if !dir.IsDir() {
+		return fmt.Sprintf("%s %q: %q is not a directory", key, path, filepath.Dir(path))
+	}
+
+	return ""
+}
+
+// unknownKeys reports the keys of the config file that configJSON does not
+// have, they are likely typos. Keys are matched ignoring case like
+// encoding/json does.
+func unknownKeys(values map[string]interface{}) []string {
+	knownKeys := []string{}
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(configJSON{})) {
+		knownKeys = append(knownKeys, strings.Split(field.Tag.Get("json"), ",")[0])
+	}
+
+	problems := []string{}
+	for key := range values {
+		isKnown := false
+		for _, knownKey := range knownKeys {
+			isKnown = isKnown || strings.EqualFold(key, knownKey)
+		}
+
+		if !isKnown {
+			problems = append(problems, fmt.Sprintf("unknown key %q", key))
+		}
+	}
+	sort.Strings(problems)
+
+	return problems
+}
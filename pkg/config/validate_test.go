@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidation(t *testing.T) {
+	dir := t.TempDir()
+	telegramCredentialsPath := filepath.Join(dir, "telegram-credentials.json")
+	err := os.WriteFile(telegramCredentialsPath, []byte(`{"telegramBotKey": "key", "telegramChatID": 1}`), 0600)
+	assert.NoError(t, err)
+
+	telegramCredentials := `"telegramCredentialsPath": "` + telegramCredentialsPath + `", `
+
+	t.Run("valid config", func(t *testing.T) {
+		cfgJSON := testConfigJSON(t, dir, telegramCredentials+`
+			"checkInterval": 3600,
+			"updatesToCheck": ["Grade", "Feedback"],
+			"toPrint": ["Title"],
+			"schedules": ["0 * * * *"],
+			"timeZone": "Europe/Moscow"`)
+
+		cfg, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, telegramCredentialsPath, cfg.TelegramCredentialsPath)
+		assert.Equal(t, time.Hour, cfg.CheckInterval)
+	})
+
+	t.Run("every problem at once", func(t *testing.T) {
+		cfgJSON := testConfigJSON(t, dir, telegramCredentials+`
+			"checkIntervall": 3600,
+			"checkInterval": 0,
+			"backoffMax": -1,
+			"toPrint": ["Titel"],
+			"quietHoursStart": "25:00",
+			"quietHoursEnd": "08:00",
+			"digestPath": "`+filepath.Join(dir, "missing", "digest.json")+`"`)
+
+		_, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		validationErr, ok := err.(ValidationError)
+		assert.True(t, ok)
+		assert.Equal(t, []string{
+			`unknown key "checkIntervall"`,
+			`toPrint: unknown field "Titel", expected one of ID, Title, Grade, Persentage, Feedback, Contribution, Range, Weight, Type, ParentID, Level, URL, FeedbackFiles`,
+			"backoffMax must not be negative",
+			"no schedules and no check interval",
+			`bad quiet hours start: parsing time "25:00": hour out of range`,
+		}, validationErr.Problems[:5])
+		assert.Len(t, validationErr.Problems, 6)
+		assert.Contains(t, validationErr.Problems[5], "digestPath")
+	})
+
+	t.Run("zero timeouts are the defaults", func(t *testing.T) {
+		cfgJSON := testConfigJSON(t, dir, telegramCredentials+`
+			"checkInterval": 3600,
+			"failedRequestRepeatTimeout": 0,
+			"requestTimeout": 0,
+			"courseTimeout": -1`)
+
+		_, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		validationErr, ok := err.(ValidationError)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"courseTimeout must not be negative, 0 means the default"}, validationErr.Problems)
+	})
+
+	t.Run("negative grace period", func(t *testing.T) {
+		cfgJSON := testConfigJSON(t, dir, telegramCredentials+`
+			"checkInterval": 3600,
+			"courseGracePeriod": "-24h"`)
+
+		_, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		validationErr, ok := err.(ValidationError)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"courseGracePeriod must not be negative"}, validationErr.Problems)
+	})
+
+	t.Run("bad metrics address", func(t *testing.T) {
+		cfgJSON := testConfigJSON(t, dir, telegramCredentials+`
+			"checkInterval": 3600,
+			"metricsAddr": "9090"`)
+
+		_, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		validationErr, ok := err.(ValidationError)
+		assert.True(t, ok)
+		assert.Len(t, validationErr.Problems, 1)
+		assert.Contains(t, validationErr.Problems[0], "metricsAddr")
+	})
+
+	t.Run("missing credentials", func(t *testing.T) {
+		cfgJSON := testConfigJSON(t, dir, `
+			"checkInterval": 3600,
+			"telegramCredentialsPath": "`+filepath.Join(dir, "missing.json")+`"`)
+
+		_, err := NewConfig(strings.NewReader(cfgJSON), FormatJSON, nil)
+
+		validationErr, ok := err.(ValidationError)
+		assert.True(t, ok)
+		assert.Len(t, validationErr.Problems, 2)
+		assert.Contains(t, validationErr.Problems[0], "telegram bot key is empty")
+		assert.Contains(t, validationErr.Problems[1], "telegram chat ID is empty")
+	})
+}
@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
 	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
 )
 
@@ -23,19 +25,19 @@ type SaveConfig struct {
 
 type Grades struct {
 	cfg SaveConfig
-	log *log.Logger
+	log *slog.Logger
 }
 
-func NewGrades(cfg SaveConfig, log *log.Logger) Grades {
+func NewGrades(cfg SaveConfig, log *slog.Logger) Grades {
 	return Grades{cfg, log}
 }
 
 func (grades Grades) Compare(
 	newGrades []moodle.Course,
 ) ([]CourseGradesChange, error) {
-	oldGrades, err := grades.getSaved()
+	oldGrades, err := grades.GetSaved()
 	if err != nil {
-		grades.log.Println(err)
+		grades.log.Warn("failed to get saved grades, comparing with none", logging.Err(err))
 		oldGrades = []moodle.Course{}
 	}
 
@@ -45,13 +47,73 @@ func (grades Grades) Compare(
 	return gradeChanges, nil
 }
 
-func (grades Grades) Save(gradesToSave []moodle.Course) error {
-	stream, err := json.MarshalIndent(gradesToSave, "", "\t")
+// RestoreUnavailable replaces grades and totals moodle failed to return with
+// the saved ones, so they are not reported as removed and a later change of
+// a total is compared with the saved total. Grade rows that could not be
+// parsed keep their saved version too.
+func (grades Grades) RestoreUnavailable(newGrades []moodle.Course) []moodle.Course {
+	oldGrades, err := grades.GetSaved()
 	if err != nil {
-		panic(err)
+		grades.log.Warn("failed to get saved grades to restore unavailable courses", logging.Err(err))
+		return newGrades
+	}
+
+	oldCourses := make(map[int]moodle.Course, len(oldGrades))
+	for _, oldCourse := range oldGrades {
+		oldCourses[oldCourse.ID] = oldCourse
+	}
+
+	for i := range newGrades {
+		oldCourse, ok := oldCourses[newGrades[i].ID]
+		if !ok {
+			continue
+		}
+
+		if newGrades[i].GradesUnavailable {
+			newGrades[i].Grades = oldCourse.Grades
+			newGrades[i].Categories = oldCourse.Categories
+		} else {
+			newGrades[i].Grades = restoreMalformedRows(newGrades[i], oldCourse)
+		}
+
+		if newGrades[i].TotalUnavailable {
+			newGrades[i].Total = oldCourse.Total
+		}
+	}
+
+	return newGrades
+}
+
+// restoreMalformedRows returns the grades of course with the saved rows it
+// is missing while some of its rows could not be parsed. The ID of a
+// malformed row is often unreadable, so any missing row is taken for one, it
+// is reported as removed only once the table parses again.
+func restoreMalformedRows(course, oldCourse moodle.Course) []moodle.GradeReport {
+	hasMalformedRows := false
+	for _, warning := range course.Warnings {
+		hasMalformedRows = hasMalformedRows || warning.Row >= 0
+	}
+	if !hasMalformedRows {
+		return course.Grades
 	}
 
-	f, err := os.OpenFile(grades.cfg.LastGradesPath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	gradeIDs := make(map[int]bool, len(course.Grades))
+	for _, grade := range course.Grades {
+		gradeIDs[grade.ID] = true
+	}
+
+	restored := course.Grades
+	for _, oldGrade := range oldCourse.Grades {
+		if !gradeIDs[oldGrade.ID] {
+			restored = append(restored, oldGrade)
+		}
+	}
+
+	return restored
+}
+
+func (grades Grades) Save(gradesToSave []moodle.Course) error {
+	stream, err := json.MarshalIndent(gradesToSave, "", "\t")
 	if err != nil {
 		return fmt.Errorf(
 			"failed to save grades to file \"%v\": %v",
@@ -59,9 +121,8 @@ func (grades Grades) Save(gradesToSave []moodle.Course) error {
 			err,
 		)
 	}
-	defer f.Close()
 
-	_, err = f.Write(stream)
+	err = writeFileAtomic(grades.cfg.LastGradesPath, stream)
 	if err != nil {
 		return fmt.Errorf(
 			"failed to save grades to file \"%v\": %v",
@@ -73,7 +134,42 @@ func (grades Grades) Save(gradesToSave []moodle.Course) error {
 	return nil
 }
 
-func (grades Grades) getSaved() ([]moodle.Course, error) {
+// writeFileAtomic writes to a temporary file and renames it over path, so
+// the process being killed midway never leaves a truncated file.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(f.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// GetSaved returns the last saved snapshot of the courses.
+func (grades Grades) GetSaved() ([]moodle.Course, error) {
 	courseGradesFile, err := os.OpenFile(grades.cfg.LastGradesPath, os.O_RDONLY|os.O_CREATE, 0644)
 	if err != nil {
 		return nil, fmt.Errorf(
@@ -0,0 +1,76 @@
+package course
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+func TestRestoreUnavailable(t *testing.T) {
+	grades := NewGrades(SaveConfig{
+		LastGradesPath: filepath.Join(t.TempDir(), "last_grades.json"),
+	}, logging.Discard())
+
+	saved := []moodle.Course{
+		{
+			ID:     1,
+			Grades: []moodle.GradeReport{{ID: 5, Title: "Lab", Grade: "8"}},
+			Total:  moodle.CourseTotal{Grade: "50.00", RawGrade: "50"},
+		},
+		{
+			ID:     2,
+			Grades: []moodle.GradeReport{{ID: 6, Title: "Quiz", Grade: "3"}},
+			Total:  moodle.CourseTotal{Grade: "70.00", RawGrade: "70"},
+		},
+	}
+	assert.NoError(t, grades.Save(saved))
+
+	t.Run("unavailable grades", func(t *testing.T) {
+		fetched := []moodle.Course{
+			{ID: 1, GradesUnavailable: true, Total: saved[0].Total},
+			{ID: 2, Grades: []moodle.GradeReport{{ID: 6, Title: "Quiz", Grade: "4"}}, Total: saved[1].Total},
+		}
+
+		restored := grades.RestoreUnavailable(fetched)
+
+		assert.Equal(t, saved[0].Grades, restored[0].Grades)
+		assert.Equal(t, "4", restored[1].Grades[0].Grade)
+	})
+
+	t.Run("malformed rows", func(t *testing.T) {
+		fetched := []moodle.Course{
+			{ID: 1, Grades: []moodle.GradeReport{}, Total: saved[0].Total, Warnings: []moodle.ParseWarning{
+				{CourseID: 1, Row: 3, RowID: "row5"},
+			}},
+			saved[1],
+		}
+
+		restored := grades.RestoreUnavailable(fetched)
+
+		assert.Equal(t, saved[0].Grades, restored[0].Grades)
+
+		changes, err := grades.Compare(restored)
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("unavailable totals", func(t *testing.T) {
+		fetched := []moodle.Course{
+			{ID: 1, Grades: saved[0].Grades, TotalUnavailable: true},
+			{ID: 2, Grades: saved[1].Grades, TotalUnavailable: true},
+		}
+
+		restored := grades.RestoreUnavailable(fetched)
+
+		assert.Equal(t, saved[0].Total, restored[0].Total)
+		assert.Equal(t, saved[1].Total, restored[1].Total)
+
+		changes, err := grades.Compare(restored)
+		assert.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+}
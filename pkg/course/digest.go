@@ -0,0 +1,134 @@
+package course
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+// Digest collects changes over a period, e.g. quiet hours, as the difference
+// between the snapshot at its start and the last one. A grade changed and
+// changed back during the period is not reported. The start snapshot is kept
+// in the file at path while the digest is open, so it survives restarts, an
+// empty path keeps it in memory only.
+type Digest struct {
+	path   string
+	base   []moodle.Course
+	isOpen bool
+	log    *slog.Logger
+}
+
+func NewDigest(path string, log *slog.Logger) *Digest {
+	digest := &Digest{path: path, log: log}
+
+	err := digest.load()
+	if err != nil {
+		log.Warn("failed to load digest", logging.Err(err))
+	}
+
+	return digest
+}
+
+// Open starts the period with the snapshot taken before it, opening an
+// already open digest keeps its start.
+func (d *Digest) Open(snapshot []moodle.Course) {
+	if d.isOpen {
+		return
+	}
+
+	if snapshot == nil {
+		snapshot = []moodle.Course{}
+	}
+
+	d.base = snapshot
+	d.isOpen = true
+
+	err := d.save()
+	if err != nil {
+		d.log.Warn("failed to save digest", logging.Err(err))
+	}
+}
+
+func (d *Digest) IsOpen() bool {
+	return d.isOpen
+}
+
+// Pending returns the changes from the start of the period to snapshot
+// without closing the digest.
+func (d *Digest) Pending(snapshot []moodle.Course) []CourseGradesChange {
+	if !d.isOpen {
+		return []CourseGradesChange{}
+	}
+
+	base := make([]moodle.Course, len(d.base))
+	copy(base, d.base)
+
+	gc := newGradesComparator(d.log)
+
+	return gc.compareCourseGrades(base, snapshot)
+}
+
+// Close ends the period and returns the changes from its start to snapshot.
+func (d *Digest) Close(snapshot []moodle.Course) []CourseGradesChange {
+	changes := d.Pending(snapshot)
+
+	d.base = nil
+	d.isOpen = false
+
+	if d.path != "" {
+		err := os.Remove(d.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			d.log.Warn("failed to remove digest", "path", d.path, logging.Err(err))
+		}
+	}
+
+	return changes
+}
+
+func (d *Digest) load() error {
+	if d.path == "" {
+		return nil
+	}
+
+	digestJSON, err := os.ReadFile(d.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read digest from \"%v\": %v", d.path, err)
+	}
+
+	var base []moodle.Course
+	err = json.Unmarshal(digestJSON, &base)
+	if err != nil {
+		return fmt.Errorf("failed to read digest from \"%v\": %v", d.path, err)
+	}
+
+	d.base = base
+	d.isOpen = true
+
+	return nil
+}
+
+func (d *Digest) save() error {
+	if d.path == "" {
+		return nil
+	}
+
+	digestJSON, err := json.Marshal(d.base)
+	if err != nil {
+		return fmt.Errorf("failed to save digest to \"%v\": %v", d.path, err)
+	}
+
+	err = writeFileAtomic(d.path, digestJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save digest to \"%v\": %v", d.path, err)
+	}
+
+	return nil
+}
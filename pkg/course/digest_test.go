@@ -0,0 +1,55 @@
+package course
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+func TestDigest(t *testing.T) {
+	course := moodle.Course{ID: 1, Fullname: "AGLA"}
+	snapshot := func(grade string) []moodle.Course {
+		return []moodle.Course{withGrades(course, []moodle.GradeReport{{ID: 5, Title: "Lab", Grade: grade}})}
+	}
+
+	t.Run("net changes of the period", func(t *testing.T) {
+		digest := NewDigest("", logging.Discard())
+
+		digest.Open(snapshot("5"))
+		digest.Open(snapshot("6"))
+		assert.True(t, digest.IsOpen())
+
+		changes := digest.Close(snapshot("7"))
+
+		assert.False(t, digest.IsOpen())
+		assert.Len(t, changes, 1)
+		assert.Equal(t, "5", changes[0].GradesTableChange[0].From.Grade)
+		assert.Equal(t, "7", changes[0].GradesTableChange[0].To.Grade)
+	})
+
+	t.Run("changed back", func(t *testing.T) {
+		digest := NewDigest("", logging.Discard())
+
+		digest.Open(snapshot("5"))
+
+		assert.Empty(t, digest.Close(snapshot("5")))
+	})
+
+	t.Run("survives restarts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "digest.json")
+		logger := logging.Discard()
+
+		NewDigest(path, logger).Open(snapshot("5"))
+
+		digest := NewDigest(path, logger)
+		assert.True(t, digest.IsOpen())
+		assert.Len(t, digest.Pending(snapshot("7")), 1)
+
+		digest.Close(snapshot("7"))
+		assert.False(t, NewDigest(path, logger).IsOpen())
+	})
+}
@@ -1,17 +1,27 @@
 package course
 
 import (
-	"log"
+	"log/slog"
 	"sort"
 
 	"github.com/r3labs/diff/v3"
+	"golang.org/x/exp/slices"
 
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
 	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
 )
 
 type CourseGradesChange struct {
 	Course            moodle.Course
 	GradesTableChange []GradeRowChange
+	TotalChange       CourseTotalChange
+}
+
+type CourseTotalChange struct {
+	Type  string
+	From  moodle.CourseTotal
+	To    moodle.CourseTotal
+	Delta GradeDelta
 }
 
 type GradeRowChange struct {
@@ -20,13 +30,50 @@ type GradeRowChange struct {
 	Fields []string
 	From   moodle.GradeReport
 	To     moodle.GradeReport
+	Delta  GradeDelta
+}
+
+type GradeDelta struct {
+	Points        float64
+	HasPoints     bool
+	Percentage    float64
+	HasPercentage bool
+}
+
+func HasTotalChanges(courseChanges []CourseGradesChange) bool {
+	for _, courseChange := range courseChanges {
+		if courseChange.TotalChange.Type == "update" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CountChanges counts the changes by type: create, update or remove of a
+// grade row, or total for a changed course total.
+func CountChanges(courseChanges []CourseGradesChange) map[string]int {
+	counts := map[string]int{}
+	for _, courseChange := range courseChanges {
+		for _, rowChange := range courseChange.GradesTableChange {
+			if rowChange.Type != "nochange" {
+				counts[rowChange.Type]++
+			}
+		}
+
+		if courseChange.TotalChange.Type == "update" {
+			counts["total"]++
+		}
+	}
+
+	return counts
 }
 
 type gradesComparator struct {
-	log *log.Logger
+	log *slog.Logger
 }
 
-func newGradesComparator(log *log.Logger) gradesComparator {
+func newGradesComparator(log *slog.Logger) gradesComparator {
 	return gradesComparator{log}
 }
 
@@ -44,9 +91,13 @@ func (gc gradesComparator) compareCourseGrades(from, to []moodle.Course) []Cours
 		toCourse := to[toCourseInx]
 
 		gradesTableChange := []GradeRowChange{}
+		totalChange := CourseTotalChange{Type: "nochange"}
+
+		changedCourse := fromCourse
 
 		newCourseAdded := fromCourse.ID > toCourse.ID
 		if newCourseAdded {
+			changedCourse = toCourse
 			gradesTableChange = gc.compareGradeReports(nil, toCourse.Grades)
 			toCourseInx++
 		}
@@ -60,18 +111,20 @@ func (gc gradesComparator) compareCourseGrades(from, to []moodle.Course) []Cours
 		theSameCourses := fromCourse.ID == toCourse.ID
 		if theSameCourses {
 			gradesTableChange = gc.compareGradeReports(fromCourse.Grades, toCourse.Grades)
+			totalChange = gc.compareCourseTotals(fromCourse.Total, toCourse.Total)
 			fromCourseInx++
 			toCourseInx++
 		}
 
-		noUpdates := len(gradesTableChange) == 0
+		noUpdates := len(gradesTableChange) == 0 && totalChange.Type == "nochange"
 		if noUpdates {
 			continue
 		}
 
 		courseGradesChanges := CourseGradesChange{
-			Course:            fromCourse,
+			Course:            changedCourse,
 			GradesTableChange: gradesTableChange,
+			TotalChange:       totalChange,
 		}
 		courseGradesChange = append(courseGradesChange, courseGradesChanges)
 	}
@@ -79,27 +132,35 @@ func (gc gradesComparator) compareCourseGrades(from, to []moodle.Course) []Cours
 	for fromCourseInx < len(from) {
 		fromCourse := from[fromCourseInx]
 		gradesTableChange := gc.compareGradeReports(fromCourse.Grades, nil)
+		fromCourseInx++
+
+		if len(gradesTableChange) == 0 {
+			continue
+		}
 
 		courseGradesChanges := CourseGradesChange{
 			Course:            fromCourse,
 			GradesTableChange: gradesTableChange,
+			TotalChange:       CourseTotalChange{Type: "nochange"},
 		}
 		courseGradesChange = append(courseGradesChange, courseGradesChanges)
-
-		fromCourseInx++
 	}
 
 	for toCourseInx < len(to) {
 		toCourse := to[toCourseInx]
 		gradesTableChange := gc.compareGradeReports(nil, toCourse.Grades)
+		toCourseInx++
+
+		if len(gradesTableChange) == 0 {
+			continue
+		}
 
 		courseGradesChanges := CourseGradesChange{
 			Course:            toCourse,
 			GradesTableChange: gradesTableChange,
+			TotalChange:       CourseTotalChange{Type: "nochange"},
 		}
 		courseGradesChange = append(courseGradesChange, courseGradesChanges)
-
-		toCourseInx++
 	}
 
 	return courseGradesChange
@@ -125,9 +186,9 @@ func (gc gradesComparator) compareGradeReports(
 
 			createdGrade := GradeRowChange{
 				Type:   "create",
-				ID:     fromGrade.ID,
+				ID:     toGrade.ID,
 				Fields: []string{},
-				To:     fromGrade,
+				To:     toGrade,
 			}
 			gradesTableChnages = append(gradesTableChnages, createdGrade)
 
@@ -140,7 +201,7 @@ func (gc gradesComparator) compareGradeReports(
 
 			removedGrade := GradeRowChange{
 				Type:   "remove",
-				ID:     toGrade.ID,
+				ID:     fromGrade.ID,
 				Fields: []string{},
 				From:   fromGrade,
 			}
@@ -161,26 +222,26 @@ func (gc gradesComparator) compareGradeReports(
 
 	for toGradeInx < len(to) {
 		toGrade := to[toGradeInx]
-		removedGrade := GradeRowChange{
+		createdGrade := GradeRowChange{
 			Type:   "create",
 			ID:     toGrade.ID,
 			Fields: []string{},
 			To:     toGrade,
 		}
-		gradesTableChnages = append(gradesTableChnages, removedGrade)
+		gradesTableChnages = append(gradesTableChnages, createdGrade)
 
 		toGradeInx++
 	}
 
 	for fromGradeInx < len(from) {
 		fromGrade := from[fromGradeInx]
-		createdGrade := GradeRowChange{
+		removedGrade := GradeRowChange{
 			Type:   "remove",
 			ID:     fromGrade.ID,
 			Fields: []string{},
 			From:   fromGrade,
 		}
-		gradesTableChnages = append(gradesTableChnages, createdGrade)
+		gradesTableChnages = append(gradesTableChnages, removedGrade)
 
 		fromGradeInx++
 	}
@@ -191,7 +252,8 @@ func (gc gradesComparator) compareGradeReports(
 func (gc gradesComparator) compareGrades(from, to moodle.GradeReport) GradeRowChange {
 	changes, err := diff.Diff(from, to)
 	if err != nil {
-		panic(err)
+		gc.log.Warn("failed to compare grade", "title", to.Title, logging.Err(err))
+		return GradeRowChange{Type: "nochange"}
 	}
 
 	if len(changes) == 0 {
@@ -207,11 +269,16 @@ func (gc gradesComparator) compareGrades(from, to moodle.GradeReport) GradeRowCh
 	}
 
 	for _, change := range changes {
-		if gc.isFieldUpdateUseless(change.To.(string)) {
+		if gc.isFieldUpdateUseless(change.To) {
+			continue
+		}
+
+		field := change.Path[0]
+		if slices.Contains(gradeRowChange.Fields, field) {
 			continue
 		}
 
-		gradeRowChange.Fields = append(gradeRowChange.Fields, change.Path...)
+		gradeRowChange.Fields = append(gradeRowChange.Fields, field)
 	}
 
 	areNoFieldsUpdated := len(gradeRowChange.Fields) == 0
@@ -219,11 +286,61 @@ func (gc gradesComparator) compareGrades(from, to moodle.GradeReport) GradeRowCh
 		return GradeRowChange{Type: "nochange"}
 	}
 
+	gradeRowChange.Delta = gc.computeDelta(from, to)
+
 	return gradeRowChange
 }
 
-func (gc gradesComparator) isFieldUpdateUseless(to string) bool {
-	if to == "Error" || to == "-" {
+// compareCourseTotals ignores totals missing in the old snapshot, they were
+// not fetched rather than not graded, moodle shows "-" for the latter.
+func (gc gradesComparator) compareCourseTotals(from, to moodle.CourseTotal) CourseTotalChange {
+	isTotalUnknown := from.Grade == "" || to.Grade == ""
+	if isTotalUnknown || from.Grade == to.Grade {
+		return CourseTotalChange{Type: "nochange"}
+	}
+
+	totalChange := CourseTotalChange{
+		Type: "update",
+		From: from,
+		To:   to,
+	}
+
+	fromValue := moodle.ParseGradeValue(from.RawGrade)
+	toValue := moodle.ParseGradeValue(to.RawGrade)
+	if fromValue.IsNumeric() && toValue.IsNumeric() {
+		totalChange.Delta.Points = toValue.Number - fromValue.Number
+		totalChange.Delta.HasPoints = totalChange.Delta.Points != 0
+	}
+
+	return totalChange
+}
+
+func (gc gradesComparator) computeDelta(from, to moodle.GradeReport) GradeDelta {
+	fromValues := from.Values()
+	toValues := to.Values()
+
+	delta := GradeDelta{}
+
+	if fromValues.Grade.IsNumeric() && toValues.Grade.IsNumeric() {
+		delta.Points = toValues.Grade.Number - fromValues.Grade.Number
+		delta.HasPoints = delta.Points != 0
+	}
+
+	if fromValues.Persentage.IsPercentage() && toValues.Persentage.IsPercentage() {
+		delta.Percentage = toValues.Persentage.Number - fromValues.Persentage.Number
+		delta.HasPercentage = delta.Percentage != 0
+	}
+
+	return delta
+}
+
+func (gc gradesComparator) isFieldUpdateUseless(to interface{}) bool {
+	toStr, ok := to.(string)
+	if !ok {
+		return false
+	}
+
+	if toStr == "Error" || toStr == "-" {
 		return true
 	}
 
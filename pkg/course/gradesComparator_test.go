@@ -5,29 +5,22 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
-	moodleapi "github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
-	moodlegrades "github.com/aDeepRecession/moodle-scrapper/pkg/moodleGrades"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
 )
 
 func TestCoursesComparison(t *testing.T) {
 	t.Run("grade row update", func(t *testing.T) {
-		gradeFrom := moodlegrades.GradeReport{ID: 5, Title: "Final exam", Grade: "-"}
-		gradeTo := moodlegrades.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
-		course := moodleapi.Course{ID: 1, Fullname: "AGLA"}
-		courseFrom := []Course{{
-			Course: course,
-			Grades: []moodlegrades.GradeReport{gradeFrom},
-		}}
-		courseTo := []Course{{
-			Course: course,
-			Grades: []moodlegrades.GradeReport{gradeTo},
-		}}
+		gradeFrom := moodle.GradeReport{ID: 5, Title: "Final exam", Grade: "-"}
+		gradeTo := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
+		course := moodle.Course{ID: 1, Fullname: "AGLA"}
+		courseFrom := []moodle.Course{withGrades(course, []moodle.GradeReport{gradeFrom})}
+		courseTo := []moodle.Course{withGrades(course, []moodle.GradeReport{gradeTo})}
 
 		gc := gradesComparator{}
 		gradecChanges := gc.compareCourseGrades(courseFrom, courseTo)
 
 		expected := []CourseGradesChange{{
-			Course: course,
+			Course: courseFrom[0],
 			GradesTableChange: []GradeRowChange{{
 				ID:     5,
 				Type:   "update",
@@ -35,28 +28,23 @@ func TestCoursesComparison(t *testing.T) {
 				From:   gradeFrom,
 				To:     gradeTo,
 			}},
+			TotalChange: CourseTotalChange{Type: "nochange"},
 		}}
 		assert.Equal(t, expected, gradecChanges)
 	})
 
 	t.Run("grade row update with garbage result", func(t *testing.T) {
-		gradeFrom := moodlegrades.GradeReport{ID: 5, Title: "Final exam", Grade: "-"}
-		gradeTo := moodlegrades.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "Error"}
-		course := moodleapi.Course{ID: 1, Fullname: "AGLA"}
-		courseFrom := []Course{{
-			Course: course,
-			Grades: []moodlegrades.GradeReport{gradeFrom},
-		}}
-		courseTo := []Course{{
-			Course: course,
-			Grades: []moodlegrades.GradeReport{gradeTo},
-		}}
+		gradeFrom := moodle.GradeReport{ID: 5, Title: "Final exam", Grade: "-"}
+		gradeTo := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "Error"}
+		course := moodle.Course{ID: 1, Fullname: "AGLA"}
+		courseFrom := []moodle.Course{withGrades(course, []moodle.GradeReport{gradeFrom})}
+		courseTo := []moodle.Course{withGrades(course, []moodle.GradeReport{gradeTo})}
 
 		gc := gradesComparator{}
 		gradecChanges := gc.compareCourseGrades(courseFrom, courseTo)
 
 		expected := []CourseGradesChange{{
-			Course: course,
+			Course: courseFrom[0],
 			GradesTableChange: []GradeRowChange{{
 				ID:     5,
 				Type:   "update",
@@ -64,6 +52,7 @@ func TestCoursesComparison(t *testing.T) {
 				From:   gradeFrom,
 				To:     gradeTo,
 			}},
+			TotalChange: CourseTotalChange{Type: "nochange"},
 		}}
 		assert.Equal(t, expected, gradecChanges)
 	})
@@ -72,73 +61,226 @@ func TestCoursesComparison(t *testing.T) {
 	})
 
 	t.Run("new grade row", func(t *testing.T) {
-		oldGrade := moodlegrades.GradeReport{ID: 2, Title: "midterm", Grade: "-"}
-		newGrade := moodlegrades.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
-		course := moodleapi.Course{ID: 1, Fullname: "AGLA"}
-		courseFrom := []Course{{
-			Course: course,
-			Grades: []moodlegrades.GradeReport{oldGrade},
-		}}
-		courseTo := []Course{{
-			Course: course,
-			Grades: []moodlegrades.GradeReport{oldGrade, newGrade},
-		}}
+		oldGrade := moodle.GradeReport{ID: 2, Title: "midterm", Grade: "-"}
+		newGrade := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
+		course := moodle.Course{ID: 1, Fullname: "AGLA"}
+		courseFrom := []moodle.Course{withGrades(course, []moodle.GradeReport{oldGrade})}
+		courseTo := []moodle.Course{withGrades(course, []moodle.GradeReport{oldGrade, newGrade})}
 
 		gc := gradesComparator{}
 		gradecChanges := gc.compareCourseGrades(courseFrom, courseTo)
 
 		expected := []CourseGradesChange{{
-			Course: course,
+			Course: courseFrom[0],
 			GradesTableChange: []GradeRowChange{{
 				ID:     5,
 				Type:   "create",
 				Fields: []string{},
 				To:     newGrade,
 			}},
+			TotalChange: CourseTotalChange{Type: "nochange"},
 		}}
 		assert.Equal(t, expected, gradecChanges)
 	})
 
 	t.Run("row deleted", func(t *testing.T) {
+		removedGrade := moodle.GradeReport{ID: 2, Title: "midterm", Grade: "-"}
+		keptGrade := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
+		course := moodle.Course{ID: 1, Fullname: "AGLA"}
+		courseFrom := []moodle.Course{withGrades(course, []moodle.GradeReport{removedGrade, keptGrade})}
+		courseTo := []moodle.Course{withGrades(course, []moodle.GradeReport{keptGrade})}
+
+		gc := gradesComparator{}
+		gradecChanges := gc.compareCourseGrades(courseFrom, courseTo)
+
+		expected := []CourseGradesChange{{
+			Course: courseFrom[0],
+			GradesTableChange: []GradeRowChange{{
+				ID:     2,
+				Type:   "remove",
+				Fields: []string{},
+				From:   removedGrade,
+			}},
+			TotalChange: CourseTotalChange{Type: "nochange"},
+		}}
+		assert.Equal(t, expected, gradecChanges)
+	})
+
+	t.Run("row added before existing ones", func(t *testing.T) {
+		newGrade := moodle.GradeReport{ID: 2, Title: "midterm", Grade: "40"}
+		oldGrade := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "-"}
+		course := moodle.Course{ID: 1, Fullname: "AGLA"}
+		courseFrom := []moodle.Course{withGrades(course, []moodle.GradeReport{oldGrade})}
+		courseTo := []moodle.Course{withGrades(course, []moodle.GradeReport{newGrade, oldGrade})}
+
+		gc := gradesComparator{}
+		gradecChanges := gc.compareCourseGrades(courseFrom, courseTo)
+
+		expected := []CourseGradesChange{{
+			Course: courseFrom[0],
+			GradesTableChange: []GradeRowChange{{
+				ID:     2,
+				Type:   "create",
+				Fields: []string{},
+				To:     newGrade,
+			}},
+			TotalChange: CourseTotalChange{Type: "nochange"},
+		}}
+		assert.Equal(t, expected, gradecChanges)
 	})
 
 	t.Run("course deleted", func(t *testing.T) {
+		grade := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
+		removedCourse := withGrades(moodle.Course{ID: 1, Fullname: "AGLA"}, []moodle.GradeReport{grade})
+		keptCourse := withGrades(moodle.Course{ID: 2, Fullname: "Physics"}, []moodle.GradeReport{grade})
+		courseFrom := []moodle.Course{removedCourse, keptCourse}
+		courseTo := []moodle.Course{keptCourse}
+
+		gc := gradesComparator{}
+		gradecChanges := gc.compareCourseGrades(courseFrom, courseTo)
+
+		expected := []CourseGradesChange{{
+			Course: removedCourse,
+			GradesTableChange: []GradeRowChange{{
+				ID:     5,
+				Type:   "remove",
+				Fields: []string{},
+				From:   grade,
+			}},
+			TotalChange: CourseTotalChange{Type: "nochange"},
+		}}
+		assert.Equal(t, expected, gradecChanges)
 	})
 
-	t.Run("new course", func(t *testing.T) {
-		gradeTo := moodlegrades.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
-		course := moodleapi.Course{ID: 1, Fullname: "AGLA"}
-		courseFrom := []Course{}
-		courseTo := []Course{{
-			Course: course,
-			Grades: []moodlegrades.GradeReport{gradeTo},
+	t.Run("new course before existing ones", func(t *testing.T) {
+		grade := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
+		newCourse := withGrades(moodle.Course{ID: 1, Fullname: "AGLA"}, []moodle.GradeReport{grade})
+		keptCourse := withGrades(moodle.Course{ID: 2, Fullname: "Physics"}, []moodle.GradeReport{grade})
+		courseFrom := []moodle.Course{keptCourse}
+		courseTo := []moodle.Course{newCourse, keptCourse}
+
+		gc := gradesComparator{}
+		gradecChanges := gc.compareCourseGrades(courseFrom, courseTo)
+
+		expected := []CourseGradesChange{{
+			Course: newCourse,
+			GradesTableChange: []GradeRowChange{{
+				ID:     5,
+				Type:   "create",
+				Fields: []string{},
+				To:     grade,
+			}},
+			TotalChange: CourseTotalChange{Type: "nochange"},
 		}}
+		assert.Equal(t, expected, gradecChanges)
+	})
+
+	t.Run("courses without grades", func(t *testing.T) {
+		courseFrom := []moodle.Course{{ID: 1, Fullname: "AGLA"}}
+		courseTo := []moodle.Course{{ID: 2, Fullname: "Physics"}}
+
+		gc := gradesComparator{}
+
+		assert.Empty(t, gc.compareCourseGrades(courseFrom, courseTo))
+	})
+
+	t.Run("new course", func(t *testing.T) {
+		gradeTo := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
+		course := moodle.Course{ID: 1, Fullname: "AGLA"}
+		courseFrom := []moodle.Course{}
+		courseTo := []moodle.Course{withGrades(course, []moodle.GradeReport{gradeTo})}
 
 		gc := gradesComparator{}
 		gradecChanges := gc.compareCourseGrades(courseFrom, courseTo)
 
 		expected := []CourseGradesChange{{
-			Course: course,
+			Course: courseTo[0],
 			GradesTableChange: []GradeRowChange{{
 				ID:     5,
 				Type:   "create",
 				Fields: []string{},
 				To:     gradeTo,
 			}},
+			TotalChange: CourseTotalChange{Type: "nochange"},
 		}}
 		assert.Equal(t, expected, gradecChanges)
 	})
 }
 
+func TestCourseTotalsComparison(t *testing.T) {
+	t.Run("course total update", func(t *testing.T) {
+		courseFrom := moodle.Course{ID: 1, Fullname: "AGLA", Total: moodle.CourseTotal{Grade: "71.30", RawGrade: "71.3"}}
+		courseTo := moodle.Course{ID: 1, Fullname: "AGLA", Total: moodle.CourseTotal{Grade: "74.80", RawGrade: "74.8"}}
+
+		gc := gradesComparator{}
+		gradecChanges := gc.compareCourseGrades([]moodle.Course{courseFrom}, []moodle.Course{courseTo})
+
+		assert.Len(t, gradecChanges, 1)
+		assert.Equal(t, "update", gradecChanges[0].TotalChange.Type)
+		assert.Equal(t, courseTo.Total, gradecChanges[0].TotalChange.To)
+		assert.True(t, gradecChanges[0].TotalChange.Delta.HasPoints)
+		assert.InDelta(t, 3.5, gradecChanges[0].TotalChange.Delta.Points, 0.0001)
+	})
+
+	t.Run("unknown old course total", func(t *testing.T) {
+		courseFrom := moodle.Course{ID: 1, Fullname: "AGLA"}
+		courseTo := moodle.Course{ID: 1, Fullname: "AGLA", Total: moodle.CourseTotal{Grade: "74.80", RawGrade: "74.8"}}
+
+		gc := gradesComparator{}
+		gradecChanges := gc.compareCourseGrades([]moodle.Course{courseFrom}, []moodle.Course{courseTo})
+
+		assert.Empty(t, gradecChanges)
+	})
+}
+
+func TestGradesDelta(t *testing.T) {
+	t.Run("numeric grade and percentage update", func(t *testing.T) {
+		gradeFrom := moodle.GradeReport{ID: 5, Title: "Lab 4", Grade: "5.50", Persentage: "55.00 %"}
+		gradeTo := moodle.GradeReport{ID: 5, Title: "Lab 4", Grade: "8.00", Persentage: "80.00 %"}
+
+		gc := gradesComparator{}
+		change := gc.compareGrades(gradeFrom, gradeTo)
+
+		expected := GradeDelta{
+			Points:        2.5,
+			HasPoints:     true,
+			Percentage:    25,
+			HasPercentage: true,
+		}
+		assert.Equal(t, expected, change.Delta)
+	})
+
+	t.Run("no delta for a first grade", func(t *testing.T) {
+		gradeFrom := moodle.GradeReport{ID: 5, Title: "Lab 4", Grade: "-", Persentage: "-"}
+		gradeTo := moodle.GradeReport{ID: 5, Title: "Lab 4", Grade: "8.00", Persentage: "80.00 %"}
+
+		gc := gradesComparator{}
+		change := gc.compareGrades(gradeFrom, gradeTo)
+
+		assert.Equal(t, GradeDelta{}, change.Delta)
+	})
+
+	t.Run("letter grades have no delta", func(t *testing.T) {
+		gradeFrom := moodle.GradeReport{ID: 5, Title: "Essay", Grade: "D"}
+		gradeTo := moodle.GradeReport{ID: 5, Title: "Essay", Grade: "C"}
+
+		gc := gradesComparator{}
+		change := gc.compareGrades(gradeFrom, gradeTo)
+
+		assert.Equal(t, []string{"Grade"}, change.Fields)
+		assert.Equal(t, GradeDelta{}, change.Delta)
+	})
+}
+
 func TestGradesTablesComparison(t *testing.T) {
 	t.Run("grade row update", func(t *testing.T) {
-		gradeFrom := moodlegrades.GradeReport{ID: 5, Title: "Final exam", Grade: "-"}
-		gradeTo := moodlegrades.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
-		from := []moodlegrades.GradeReport{
+		gradeFrom := moodle.GradeReport{ID: 5, Title: "Final exam", Grade: "-"}
+		gradeTo := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
+		from := []moodle.GradeReport{
 			gradeFrom,
 		}
 
-		to := []moodlegrades.GradeReport{
+		to := []moodle.GradeReport{
 			gradeTo,
 		}
 
@@ -158,17 +300,17 @@ func TestGradesTablesComparison(t *testing.T) {
 	})
 
 	t.Run("several grade rows update", func(t *testing.T) {
-		finalGradeFrom := moodlegrades.GradeReport{ID: 5, Title: "Final exam", Grade: "-"}
-		finalGradeTo := moodlegrades.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
-		midGradeFrom := moodlegrades.GradeReport{ID: 3, Title: "Mid exam", Grade: "-"}
-		midGradeTo := moodlegrades.GradeReport{ID: 3, Title: "MID EXAM", Grade: "50"}
+		finalGradeFrom := moodle.GradeReport{ID: 5, Title: "Final exam", Grade: "-"}
+		finalGradeTo := moodle.GradeReport{ID: 5, Title: "FINAL EXAM", Grade: "60"}
+		midGradeFrom := moodle.GradeReport{ID: 3, Title: "Mid exam", Grade: "-"}
+		midGradeTo := moodle.GradeReport{ID: 3, Title: "MID EXAM", Grade: "50"}
 
-		from := []moodlegrades.GradeReport{
+		from := []moodle.GradeReport{
 			midGradeFrom,
 			finalGradeFrom,
 		}
 
-		to := []moodlegrades.GradeReport{
+		to := []moodle.GradeReport{
 			finalGradeTo,
 			midGradeTo,
 		}
@@ -196,11 +338,11 @@ func TestGradesTablesComparison(t *testing.T) {
 	})
 
 	t.Run("new grade row", func(t *testing.T) {
-		gradeFrom := moodlegrades.GradeReport{}
-		gradeTo := moodlegrades.GradeReport{ID: 1, Title: "FINAL EXAM", Grade: "60"}
+		gradeFrom := moodle.GradeReport{}
+		gradeTo := moodle.GradeReport{ID: 1, Title: "FINAL EXAM", Grade: "60"}
 
-		from := []moodlegrades.GradeReport{}
-		to := []moodlegrades.GradeReport{gradeTo}
+		from := []moodle.GradeReport{}
+		to := []moodle.GradeReport{gradeTo}
 
 		gc := gradesComparator{}
 		changelog := gc.compareGradeReports(from, to)
@@ -218,11 +360,11 @@ func TestGradesTablesComparison(t *testing.T) {
 	})
 
 	t.Run("row deleted", func(t *testing.T) {
-		gradeFrom := moodlegrades.GradeReport{ID: 1, Title: "FINAL EXAM", Grade: "60"}
-		gradeTo := moodlegrades.GradeReport{}
+		gradeFrom := moodle.GradeReport{ID: 1, Title: "FINAL EXAM", Grade: "60"}
+		gradeTo := moodle.GradeReport{}
 
-		from := []moodlegrades.GradeReport{gradeFrom}
-		to := []moodlegrades.GradeReport{}
+		from := []moodle.GradeReport{gradeFrom}
+		to := []moodle.GradeReport{}
 
 		gc := gradesComparator{}
 		changelog := gc.compareGradeReports(from, to)
@@ -239,3 +381,24 @@ func TestGradesTablesComparison(t *testing.T) {
 		assert.Equal(t, expect, changelog)
 	})
 }
+
+func TestCountChanges(t *testing.T) {
+	changes := []CourseGradesChange{
+		{
+			GradesTableChange: []GradeRowChange{{Type: "update"}, {Type: "create"}, {Type: "update"}},
+			TotalChange:       CourseTotalChange{Type: "update"},
+		},
+		{
+			GradesTableChange: []GradeRowChange{{Type: "remove"}, {Type: "nochange"}},
+			TotalChange:       CourseTotalChange{Type: "nochange"},
+		},
+	}
+
+	assert.Equal(t, map[string]int{"update": 2, "create": 1, "remove": 1, "total": 1}, CountChanges(changes))
+	assert.Empty(t, CountChanges(nil))
+}
+
+func withGrades(course moodle.Course, grades []moodle.GradeReport) moodle.Course {
+	course.Grades = grades
+	return course
+}
@@ -0,0 +1,93 @@
+package course
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+// SaveHistory appends the changes found at checkTime to the history file, a
+// JSON line per check. Grades of the courses are not kept, the changed rows
+// and the categories are enough to print the changes again.
+func (grades Grades) SaveHistory(changes []CourseGradesChange, checkTime time.Time) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	historyChanges := make([]CourseGradesChange, 0, len(changes))
+	for _, change := range changes {
+		change.Course.Grades = nil
+		change.Course.Fingerprint = moodle.CourseFingerprint{}
+		historyChanges = append(historyChanges, change)
+	}
+
+	record, err := json.Marshal(CourseGradesHistoryField{Time: checkTime, Updates: historyChanges})
+	if err != nil {
+		return fmt.Errorf("failed to save history to \"%v\": %v", grades.cfg.GradesHistoryPath, err)
+	}
+
+	f, err := os.OpenFile(grades.cfg.GradesHistoryPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to save history to \"%v\": %v", grades.cfg.GradesHistoryPath, err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(record, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to save history to \"%v\": %v", grades.cfg.GradesHistoryPath, err)
+	}
+
+	return nil
+}
+
+// GetHistory returns the history records with from <= Time < to, a zero
+// bound is not checked.
+func (grades Grades) GetHistory(from, to time.Time) ([]CourseGradesHistoryField, error) {
+	f, err := os.Open(grades.cfg.GradesHistoryPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return []CourseGradesHistoryField{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history from \"%v\": %v", grades.cfg.GradesHistoryPath, err)
+	}
+	defer f.Close()
+
+	history := []CourseGradesHistoryField{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record CourseGradesHistoryField
+		err = json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to read history from \"%v\": line %d: %v",
+				grades.cfg.GradesHistoryPath,
+				line,
+				err,
+			)
+		}
+
+		isAfterFrom := from.IsZero() || !record.Time.Before(from)
+		isBeforeTo := to.IsZero() || record.Time.Before(to)
+		if isAfterFrom && isBeforeTo {
+			history = append(history, record)
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history from \"%v\": %v", grades.cfg.GradesHistoryPath, err)
+	}
+
+	return history, nil
+}
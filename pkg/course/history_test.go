@@ -0,0 +1,63 @@
+package course
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+func TestHistory(t *testing.T) {
+	grades := NewGrades(SaveConfig{
+		GradesHistoryPath: filepath.Join(t.TempDir(), "history.jsonl"),
+	}, logging.Discard())
+
+	day := func(d int) time.Time {
+		return time.Date(2023, 5, d, 12, 0, 0, 0, time.UTC)
+	}
+	change := func(grade string) []CourseGradesChange {
+		return []CourseGradesChange{{
+			Course: moodle.Course{
+				ID:         1,
+				Fullname:   "AGLA",
+				Grades:     []moodle.GradeReport{{ID: 5, Title: "Lab", Grade: grade}},
+				Categories: []moodle.GradeCategory{{ID: 7, Title: "Labs"}},
+			},
+			GradesTableChange: []GradeRowChange{{ID: 5, Type: "update", Fields: []string{"Grade"}}},
+		}}
+	}
+
+	t.Run("missing file is empty", func(t *testing.T) {
+		history, err := grades.GetHistory(time.Time{}, time.Time{})
+
+		assert.NoError(t, err)
+		assert.Empty(t, history)
+	})
+
+	assert.NoError(t, grades.SaveHistory(change("5"), day(1)))
+	assert.NoError(t, grades.SaveHistory(nil, day(2)))
+	assert.NoError(t, grades.SaveHistory(change("6"), day(3)))
+	assert.NoError(t, grades.SaveHistory(change("7"), day(5)))
+
+	t.Run("all records", func(t *testing.T) {
+		history, err := grades.GetHistory(time.Time{}, time.Time{})
+
+		assert.NoError(t, err)
+		assert.Len(t, history, 3)
+		assert.True(t, day(1).Equal(history[0].Time))
+		assert.Nil(t, history[0].Updates[0].Course.Grades)
+		assert.Equal(t, "Labs", history[0].Updates[0].Course.Categories[0].Title)
+	})
+
+	t.Run("between bounds", func(t *testing.T) {
+		history, err := grades.GetHistory(day(3), day(5))
+
+		assert.NoError(t, err)
+		assert.Len(t, history, 1)
+		assert.True(t, day(3).Equal(history[0].Time))
+	})
+}
@@ -0,0 +1,120 @@
+package course
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+// Diff returns the changes between two snapshots, the same ones a check
+// would find.
+func Diff(from, to []moodle.Course, log *slog.Logger) []CourseGradesChange {
+	gc := newGradesComparator(log)
+
+	return gc.compareCourseGrades(copyCourses(from), copyCourses(to))
+}
+
+// ReadSnapshot reads courses saved to path, e.g. a copy of the last grades
+// file.
+func ReadSnapshot(path string) ([]moodle.Course, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot from \"%v\": %v", path, err)
+	}
+
+	var courses []moodle.Course
+	err = json.Unmarshal(data, &courses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot from \"%v\": %v", path, err)
+	}
+
+	return courses, nil
+}
+
+// SnapshotAt rebuilds the grades as they were after the last check at or
+// before t, by undoing the later history records on the last snapshot.
+func (grades Grades) SnapshotAt(t time.Time) ([]moodle.Course, error) {
+	snapshot, err := ReadSnapshot(grades.cfg.LastGradesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := grades.GetHistory(time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(history) - 1; i >= 0 && history[i].Time.After(t); i-- {
+		snapshot = undoChanges(snapshot, history[i].Updates)
+	}
+
+	return snapshot, nil
+}
+
+func undoChanges(snapshot []moodle.Course, changes []CourseGradesChange) []moodle.Course {
+	for _, change := range changes {
+		courseInx := -1
+		for i := range snapshot {
+			if snapshot[i].ID == change.Course.ID {
+				courseInx = i
+			}
+		}
+
+		// the course was removed later, history keeps it without grades
+		if courseInx == -1 {
+			removedCourse := change.Course
+			removedCourse.Grades = nil
+			snapshot = append(snapshot, removedCourse)
+			courseInx = len(snapshot) - 1
+		}
+
+		changedCourse := &snapshot[courseInx]
+		for i := len(change.GradesTableChange) - 1; i >= 0; i-- {
+			changedCourse.Grades = undoRowChange(changedCourse.Grades, change.GradesTableChange[i])
+		}
+
+		if change.TotalChange.Type == "update" {
+			changedCourse.Total = change.TotalChange.From
+		}
+	}
+
+	return snapshot
+}
+
+func undoRowChange(rows []moodle.GradeReport, change GradeRowChange) []moodle.GradeReport {
+	undone := make([]moodle.GradeReport, 0, len(rows)+1)
+	isRestored := false
+	for _, row := range rows {
+		if row.ID != change.ID {
+			undone = append(undone, row)
+			continue
+		}
+
+		if change.Type != "create" {
+			undone = append(undone, change.From)
+			isRestored = true
+		}
+	}
+
+	if change.Type == "remove" && !isRestored {
+		undone = append(undone, change.From)
+	}
+
+	return undone
+}
+
+// copyCourses copies the courses and their grades, the comparator sorts
+// them in place.
+func copyCourses(courses []moodle.Course) []moodle.Course {
+	copied := make([]moodle.Course, 0, len(courses))
+	for _, course := range courses {
+		course.Grades = append([]moodle.GradeReport(nil), course.Grades...)
+		copied = append(copied, course)
+	}
+
+	return copied
+}
@@ -0,0 +1,76 @@
+package course
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+func TestSnapshotAt(t *testing.T) {
+	dir := t.TempDir()
+	logger := logging.Discard()
+	grades := NewGrades(SaveConfig{
+		LastGradesPath:    filepath.Join(dir, "grades.json"),
+		GradesHistoryPath: filepath.Join(dir, "history.jsonl"),
+	}, logger)
+
+	day := func(d int) time.Time {
+		return time.Date(2023, 5, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	agla := moodle.Course{ID: 1, Fullname: "AGLA", Total: moodle.CourseTotal{Grade: "10"}}
+	physics := moodle.Course{ID: 2, Fullname: "Physics", Total: moodle.CourseTotal{Grade: "0"}}
+	snapshots := [][]moodle.Course{
+		{
+			withGrades(agla, []moodle.GradeReport{{ID: 5, Title: "Lab", Grade: "5"}, {ID: 6, Title: "Quiz", Grade: "1"}}),
+			withGrades(physics, []moodle.GradeReport{{ID: 9, Title: "Exam", Grade: "-"}}),
+		},
+		{
+			withGrades(agla, []moodle.GradeReport{{ID: 5, Title: "Lab", Grade: "8"}, {ID: 7, Title: "Test", Grade: "3"}}),
+		},
+		{
+			withGrades(agla, []moodle.GradeReport{{ID: 5, Title: "Lab", Grade: "9"}, {ID: 7, Title: "Test", Grade: "3"}}),
+		},
+	}
+	snapshots[2][0].Total.Grade = "12"
+
+	assert.NoError(t, grades.Save(snapshots[0]))
+	for i, snapshot := range snapshots[1:] {
+		changes, err := grades.Compare(snapshot)
+		assert.NoError(t, err)
+		assert.NoError(t, grades.Save(snapshot))
+		assert.NoError(t, grades.SaveHistory(changes, day(i+2)))
+	}
+
+	t.Run("before any change", func(t *testing.T) {
+		snapshot, err := grades.SnapshotAt(day(1))
+
+		assert.NoError(t, err)
+		assert.Empty(t, Diff(snapshot, snapshots[0], logger))
+		assert.Empty(t, Diff(snapshots[0], snapshot, logger))
+		assert.Equal(t, "10", snapshot[0].Total.Grade)
+	})
+
+	t.Run("at a check", func(t *testing.T) {
+		snapshot, err := grades.SnapshotAt(day(2))
+
+		assert.NoError(t, err)
+		assert.Empty(t, Diff(snapshot, snapshots[1], logger))
+	})
+
+	t.Run("diff between times", func(t *testing.T) {
+		from, err := grades.SnapshotAt(day(1))
+		assert.NoError(t, err)
+		to, err := grades.SnapshotAt(day(3))
+		assert.NoError(t, err)
+
+		changes := Diff(from, to, logger)
+
+		assert.Equal(t, Diff(snapshots[0], snapshots[2], logger), changes)
+	})
+}
@@ -0,0 +1,116 @@
+package dump
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/redact"
+)
+
+const (
+	DefaultRetention = 7 * 24 * time.Hour
+
+	timeLayout = "20060102-150405"
+)
+
+// Dumps keeps the responses that could not be understood to debug them
+// later. A dump is redacted and named by its time, e.g.
+// sso-response-20230509-093035.html, the ones older than the retention
+// are removed. Dumps never fail the caller, problems are only logged.
+type Dumps struct {
+	dir       string
+	retention time.Duration
+	redactor  redact.Redactor
+	log       *slog.Logger
+}
+
+// New makes Dumps in dir, no dumps are kept if it is empty. A zero
+// retention is DefaultRetention.
+func New(dir string, retention time.Duration, redactor redact.Redactor, log *slog.Logger) Dumps {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+
+	return Dumps{dir, retention, redactor, log}
+}
+
+// Save writes data as the dump name, ext is the file extension like
+// ".html".
+func (d Dumps) Save(name, ext string, data []byte) {
+	if d.dir == "" {
+		return
+	}
+
+	now := time.Now()
+	dumpPath := filepath.Join(d.dir, name+"-"+now.Format(timeLayout)+ext)
+
+	err := d.save(dumpPath, data)
+	if err != nil {
+		d.log.Warn("failed to save a debug dump", logging.Err(err))
+		return
+	}
+	d.log.Info("saved a debug dump", "path", dumpPath)
+
+	err = d.clean(now)
+	if err != nil {
+		d.log.Warn("failed to remove old debug dumps", logging.Err(err))
+	}
+}
+
+func (d Dumps) save(dumpPath string, data []byte) error {
+	err := os.MkdirAll(d.dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(dumpPath, d.redactor.Bytes(data), 0600)
+}
+
+// clean removes the dumps older than the retention, other files of the
+// directory are kept.
+func (d Dumps) clean(now time.Time) error {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+
+	removeErrs := []string{}
+	for _, entry := range entries {
+		dumpTime, isDump := timeOf(entry.Name())
+		if !isDump || entry.IsDir() || now.Sub(dumpTime) <= d.retention {
+			continue
+		}
+
+		err = os.Remove(filepath.Join(d.dir, entry.Name()))
+		if err != nil {
+			removeErrs = append(removeErrs, err.Error())
+		}
+	}
+
+	if len(removeErrs) > 0 {
+		return fmt.Errorf("%s", strings.Join(removeErrs, "; "))
+	}
+
+	return nil
+}
+
+// timeOf parses the time of a dump file name, it reports whether the name
+// is of a dump.
+func timeOf(fileName string) (time.Time, bool) {
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	if len(base) <= len(timeLayout) {
+		return time.Time{}, false
+	}
+
+	dumpTime, err := time.ParseInLocation(timeLayout, base[len(base)-len(timeLayout):], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return dumpTime, true
+}
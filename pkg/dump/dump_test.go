@@ -0,0 +1,72 @@
+package dump
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/redact"
+)
+
+func TestDumps(t *testing.T) {
+	t.Run("saved redacted", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "dumps")
+		dumps := New(dir, 0, redact.New("hunter2"), logging.Discard())
+
+		dumps.Save("sso-response", ".html", []byte(`<input name="code" value="abc" /> hunter2`))
+
+		entries, err := os.ReadDir(dir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		data, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+		assert.NoError(t, err)
+		assert.Equal(t, `<input name="code" value="[REDACTED]" /> [REDACTED]`, string(data))
+	})
+
+	t.Run("old dumps removed", func(t *testing.T) {
+		dir := t.TempDir()
+		old := "sso-response-" + time.Now().Add(-2*time.Hour).Format(timeLayout) + ".html"
+		recent := "sso-response-" + time.Now().Add(-time.Minute).Format(timeLayout) + ".html"
+		for _, name := range []string{old, recent, "notes.txt"} {
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0600))
+		}
+		dumps := New(dir, time.Hour, redact.New(), logging.Discard())
+
+		err := dumps.clean(time.Now())
+
+		assert.NoError(t, err)
+		_, err = os.Stat(filepath.Join(dir, old))
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(dir, recent))
+		assert.NoError(t, err)
+		_, err = os.Stat(filepath.Join(dir, "notes.txt"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("failures are logged", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "file")
+		assert.NoError(t, os.WriteFile(file, nil, 0600))
+		out := bytes.Buffer{}
+		dumps := New(filepath.Join(file, "dumps"), 0, redact.New(), slog.New(slog.NewTextHandler(&out, nil)))
+
+		dumps.Save("sso-response", ".html", []byte("response"))
+
+		assert.Contains(t, out.String(), "failed to save a debug dump")
+	})
+
+	t.Run("no directory", func(t *testing.T) {
+		out := bytes.Buffer{}
+		dumps := New("", 0, redact.New(), slog.New(slog.NewTextHandler(&out, nil)))
+
+		dumps.Save("sso-response", ".html", []byte("response"))
+
+		assert.Empty(t, out.String())
+	})
+}
@@ -0,0 +1,236 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+	FormatXLSX  Format = "xlsx"
+)
+
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case FormatCSV, FormatJSONL, FormatXLSX:
+		return Format(format), nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected csv, jsonl or xlsx", format)
+	}
+}
+
+// Table is the exported data, every row has a value per column.
+type Table struct {
+	Name    string
+	Columns []string
+	Rows    [][]string
+}
+
+// gradeReportFields are the fields of moodle.GradeReport in declaration
+// order, the snapshot columns are named after them, so the schema changes
+// only when GradeReport does.
+var gradeReportFields = reflect.VisibleFields(reflect.TypeOf(moodle.GradeReport{}))
+
+var historyColumns = []string{
+	"time",
+	"course_id",
+	"course_name",
+	"item_id",
+	"item",
+	"change",
+	"field",
+	"from",
+	"to",
+}
+
+// SnapshotTable has a row per grade report row: course_id, course_name and
+// a column per moodle.GradeReport field in snake case.
+func SnapshotTable(courses []moodle.Course) Table {
+	columns := []string{"course_id", "course_name"}
+	for _, field := range gradeReportFields {
+		columns = append(columns, snakeCase(field.Name))
+	}
+
+	rows := [][]string{}
+	for _, snapshotCourse := range courses {
+		for _, grade := range snapshotCourse.Grades {
+			row := []string{strconv.Itoa(snapshotCourse.ID), snapshotCourse.Fullname}
+			for _, field := range gradeReportFields {
+				row = append(row, gradeField(grade, field.Name))
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return Table{Name: "grades", Columns: columns, Rows: rows}
+}
+
+// HistoryTable has a row per changed field. A created or removed row is
+// exported as a change of its grade, a course total change has item_id 0.
+func HistoryTable(history []course.CourseGradesHistoryField) Table {
+	rows := [][]string{}
+	for _, record := range history {
+		timestamp := record.Time.Format(time.RFC3339)
+
+		for _, courseChange := range record.Updates {
+			courseID := strconv.Itoa(courseChange.Course.ID)
+			courseName := courseChange.Course.Fullname
+
+			for _, rowChange := range courseChange.GradesTableChange {
+				changedRow := rowChange.To
+				if rowChange.Type == "remove" {
+					changedRow = rowChange.From
+				}
+
+				fields := rowChange.Fields
+				if rowChange.Type != "update" {
+					fields = []string{"Grade"}
+				}
+
+				for _, field := range fields {
+					rows = append(rows, []string{
+						timestamp,
+						courseID,
+						courseName,
+						strconv.Itoa(changedRow.ID),
+						changedRow.Title,
+						rowChange.Type,
+						field,
+						gradeField(rowChange.From, field),
+						gradeField(rowChange.To, field),
+					})
+				}
+			}
+
+			if courseChange.TotalChange.Type == "update" {
+				rows = append(rows, []string{
+					timestamp,
+					courseID,
+					courseName,
+					"0",
+					"Course total",
+					courseChange.TotalChange.Type,
+					"Total",
+					courseChange.TotalChange.From.Grade,
+					courseChange.TotalChange.To.Grade,
+				})
+			}
+		}
+	}
+
+	return Table{Name: "history", Columns: historyColumns, Rows: rows}
+}
+
+func Write(out io.Writer, table Table, format Format) error {
+	var err error
+	switch format {
+	case FormatJSONL:
+		err = writeJSONL(out, table)
+	case FormatXLSX:
+		err = writeXLSX(out, table)
+	default:
+		err = writeCSV(out, table)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to export %s: %v", table.Name, err)
+	}
+
+	return nil
+}
+
+func writeCSV(out io.Writer, table Table) error {
+	writer := csv.NewWriter(out)
+
+	writer.Write(table.Columns)
+	for _, row := range table.Rows {
+		writer.Write(row)
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// writeJSONL writes a JSON object per row with the keys in column order.
+func writeJSONL(out io.Writer, table Table) error {
+	for _, row := range table.Rows {
+		line := strings.Builder{}
+		line.WriteString("{")
+
+		for i, column := range table.Columns {
+			if i > 0 {
+				line.WriteString(",")
+			}
+
+			key, err := json.Marshal(column)
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(row[i])
+			if err != nil {
+				return err
+			}
+
+			line.Write(key)
+			line.WriteString(":")
+			line.Write(value)
+		}
+
+		line.WriteString("}\n")
+
+		_, err := io.WriteString(out, line.String())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func gradeField(grade moodle.GradeReport, fieldName string) string {
+	field := reflect.ValueOf(grade).FieldByName(fieldName)
+	if !field.IsValid() {
+		return ""
+	}
+
+	if field.Kind() == reflect.Slice {
+		values := []string{}
+		for i := 0; i < field.Len(); i++ {
+			values = append(values, fmt.Sprint(field.Index(i).Interface()))
+		}
+		return strings.Join(values, "\n")
+	}
+
+	return fmt.Sprint(field.Interface())
+}
+
+// snakeCase turns "ParentID" into "parent_id" and "URL" into "url".
+func snakeCase(name string) string {
+	runes := []rune(name)
+
+	snake := strings.Builder{}
+	for i, r := range runes {
+		isWordStart := i > 0 && unicode.IsUpper(r) &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])))
+		if isWordStart {
+			snake.WriteRune('_')
+		}
+		snake.WriteRune(unicode.ToLower(r))
+	}
+
+	return snake.String()
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+func TestSnakeCase(t *testing.T) {
+	assert.Equal(t, "parent_id", snakeCase("ParentID"))
+	assert.Equal(t, "url", snakeCase("URL"))
+	assert.Equal(t, "persentage", snakeCase("Persentage"))
+	assert.Equal(t, "html_title", snakeCase("HTMLTitle"))
+}
+
+func TestSnapshotTable(t *testing.T) {
+	courses := []moodle.Course{{
+		ID:       42,
+		Fullname: "AGLA",
+		Grades:   []moodle.GradeReport{{ID: 1, Title: "Lab 1", Grade: "8.00", ParentID: 7}},
+	}}
+
+	table := SnapshotTable(courses)
+
+	assert.Equal(t, []string{"course_id", "course_name"}, table.Columns[:2])
+	assert.Len(t, table.Columns, len(gradeReportFields)+2)
+	assert.Len(t, table.Rows, 1)
+
+	row := map[string]string{}
+	for i, column := range table.Columns {
+		row[column] = table.Rows[0][i]
+	}
+	assert.Equal(t, "42", row["course_id"])
+	assert.Equal(t, "Lab 1", row["title"])
+	assert.Equal(t, "8.00", row["grade"])
+	assert.Equal(t, "7", row["parent_id"])
+}
+
+func TestHistoryTable(t *testing.T) {
+	checkTime := time.Date(2023, 5, 19, 15, 4, 0, 0, time.UTC)
+	history := []course.CourseGradesHistoryField{{
+		Time: checkTime,
+		Updates: []course.CourseGradesChange{{
+			Course: moodle.Course{ID: 42, Fullname: "AGLA"},
+			GradesTableChange: []course.GradeRowChange{
+				{
+					ID:     1,
+					Type:   "update",
+					Fields: []string{"Grade", "Feedback"},
+					From:   moodle.GradeReport{ID: 1, Title: "Lab 1", Grade: "5.00"},
+					To:     moodle.GradeReport{ID: 1, Title: "Lab 1", Grade: "8.00", Feedback: "Good"},
+				},
+				{
+					ID:   2,
+					Type: "remove",
+					From: moodle.GradeReport{ID: 2, Title: "Lab 2", Grade: "3.00"},
+				},
+			},
+			TotalChange: course.CourseTotalChange{
+				Type: "update",
+				From: moodle.CourseTotal{Grade: "1.00"},
+				To:   moodle.CourseTotal{Grade: "2.00"},
+			},
+		}},
+	}}
+
+	table := HistoryTable(history)
+
+	assert.Equal(t, historyColumns, table.Columns)
+	assert.Equal(t, [][]string{
+		{"2023-05-19T15:04:00Z", "42", "AGLA", "1", "Lab 1", "update", "Grade", "5.00", "8.00"},
+		{"2023-05-19T15:04:00Z", "42", "AGLA", "1", "Lab 1", "update", "Feedback", "", "Good"},
+		{"2023-05-19T15:04:00Z", "42", "AGLA", "2", "Lab 2", "remove", "Grade", "3.00", ""},
+		{"2023-05-19T15:04:00Z", "42", "AGLA", "0", "Course total", "update", "Total", "1.00", "2.00"},
+	}, table.Rows)
+}
+
+func TestWrite(t *testing.T) {
+	table := Table{
+		Name:    "grades",
+		Columns: []string{"item", "grade"},
+		Rows:    [][]string{{"Lab \"1\"", "8 < 10"}},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		out := bytes.Buffer{}
+
+		err := Write(&out, table, FormatCSV)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "item,grade\n\"Lab \"\"1\"\"\",8 < 10\n", out.String())
+	})
+
+	t.Run("jsonl keeps column order", func(t *testing.T) {
+		out := bytes.Buffer{}
+
+		err := Write(&out, table, FormatJSONL)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "{\"item\":\"Lab \\\"1\\\"\",\"grade\":\"8 \\u003c 10\"}\n", out.String())
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		out := bytes.Buffer{}
+
+		err := Write(&out, table, FormatXLSX)
+		assert.NoError(t, err)
+
+		archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		assert.NoError(t, err)
+
+		sheet := bytes.Buffer{}
+		for _, file := range archive.File {
+			if file.Name == "xl/worksheets/sheet1.xml" {
+				f, err := file.Open()
+				assert.NoError(t, err)
+				sheet.ReadFrom(f)
+				f.Close()
+			}
+		}
+		assert.Contains(t, sheet.String(), `<c r="B2" t="inlineStr"><is><t xml:space="preserve">8 &lt; 10</t></is></c>`)
+	})
+}
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, "A", columnName(0))
+	assert.Equal(t, "Z", columnName(25))
+	assert.Equal(t, "AA", columnName(26))
+	assert.Equal(t, "AZ", columnName(51))
+	assert.Equal(t, "BA", columnName(52))
+}
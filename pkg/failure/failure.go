@@ -0,0 +1,73 @@
+package failure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+type Kind string
+
+const (
+	KindAuth       Kind = "auth"
+	KindMoodleDown Kind = "moodle down"
+	KindParse      Kind = "parse"
+	KindNotifier   Kind = "notifier"
+)
+
+// Error is a failed check with the kind of the failure.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s failure: %v", e.Kind, e.Err)
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+// Moodle classifies an error of a moodle request. Anything that is not
+// rejected credentials or an unexpected response is taken for moodle being
+// unavailable.
+func Moodle(err error) error {
+	return Error{Kind: classifyMoodle(err), Err: err}
+}
+
+func Notifier(err error) error {
+	return Error{Kind: KindNotifier, Err: err}
+}
+
+func Parse(err error) error {
+	return Error{Kind: KindParse, Err: err}
+}
+
+// KindOf returns the kind of a classified error, unclassified errors are
+// taken for moodle being unavailable.
+func KindOf(err error) Kind {
+	var failureErr Error
+	if errors.As(err, &failureErr) {
+		return failureErr.Kind
+	}
+
+	return KindMoodleDown
+}
+
+func classifyMoodle(err error) Kind {
+	if errors.Is(err, moodle.ErrWrongCredentials) {
+		return KindAuth
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var parseWarning moodle.ParseWarning
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) || errors.As(err, &parseWarning) {
+		return KindParse
+	}
+
+	return KindMoodleDown
+}
@@ -0,0 +1,86 @@
+package failure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+func TestClassification(t *testing.T) {
+	var syntaxErr error = &json.SyntaxError{}
+
+	cases := []struct {
+		name     string
+		err      error
+		expected Kind
+	}{
+		{"wrong password", Moodle(fmt.Errorf("failed to get tokens: %w", moodle.ErrWrongCredentials)), KindAuth},
+		{"bad json", Moodle(fmt.Errorf("failed to get courses: %w", syntaxErr)), KindParse},
+		{"server error", Moodle(moodle.StatusError{StatusCode: 502}), KindMoodleDown},
+		{
+			"all courses malformed",
+			Moodle(fmt.Errorf("failed to fetch grades of all 2 courses: %w", errors.Join(moodle.ParseWarning{Row: -1}, moodle.ParseWarning{Row: -1}))),
+			KindParse,
+		},
+		{"notifier", Notifier(errors.New("telegram is down")), KindNotifier},
+		{"unclassified", errors.New("something"), KindMoodleDown},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, KindOf(c.err))
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	backoff := Backoff{Base: time.Minute, Max: 10 * time.Minute, Jitter: 0.5}
+
+	assert.Equal(t, time.Minute, backoff.delay(1, 0))
+	assert.Equal(t, 4*time.Minute, backoff.delay(3, 0))
+	assert.Equal(t, 10*time.Minute, backoff.delay(30, 0))
+	assert.Equal(t, 2*time.Minute, backoff.delay(3, 1))
+}
+
+func TestTracker(t *testing.T) {
+	newTracker := func() *Tracker {
+		tracker := NewTracker(Backoff{Base: time.Minute, Max: time.Hour}, 3)
+		tracker.random = func() float64 { return 0 }
+		return tracker
+	}
+
+	t.Run("alert after consecutive failures and on recovery", func(t *testing.T) {
+		tracker := newTracker()
+		moodleDown := Moodle(errors.New("timeout"))
+
+		alerts := []bool{}
+		delays := []time.Duration{}
+		for i := 0; i < 4; i++ {
+			delay, shouldAlert := tracker.Failed(moodleDown)
+			delays = append(delays, delay)
+			alerts = append(alerts, shouldAlert)
+		}
+
+		assert.Equal(t, []bool{false, false, true, false}, alerts)
+		assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute}, delays)
+		assert.True(t, tracker.Succeeded())
+		assert.False(t, tracker.Succeeded())
+
+		delay, _ := tracker.Failed(moodleDown)
+		assert.Equal(t, time.Minute, delay)
+	})
+
+	t.Run("auth failure alerts right away", func(t *testing.T) {
+		tracker := newTracker()
+
+		_, shouldAlert := tracker.Failed(Moodle(moodle.ErrWrongCredentials))
+
+		assert.True(t, shouldAlert)
+	})
+}
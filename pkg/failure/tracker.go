@@ -0,0 +1,109 @@
+package failure
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff doubles the delay after every consecutive failure up to Max. Up
+// to Jitter of the delay is randomly taken off, so restarted instances do
+// not retry in lockstep.
+type Backoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+func DefaultBackoff() Backoff {
+	return Backoff{
+		Base:   time.Minute,
+		Max:    time.Hour,
+		Jitter: 0.2,
+	}
+}
+
+func (b Backoff) withDefaults() Backoff {
+	defaultBackoff := DefaultBackoff()
+
+	if b.Base <= 0 {
+		b.Base = defaultBackoff.Base
+	}
+	if b.Max < b.Base {
+		b.Max = b.Base
+	}
+	if b.Jitter < 0 || b.Jitter > 1 {
+		b.Jitter = defaultBackoff.Jitter
+	}
+
+	return b
+}
+
+// delay returns the delay after the failures-th consecutive failure, random
+// is a number in [0, 1).
+func (b Backoff) delay(failures int, random float64) time.Duration {
+	delay := float64(b.Base) * math.Pow(2, float64(failures-1))
+	if delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	delay -= delay * b.Jitter * random
+
+	return time.Duration(delay)
+}
+
+// Tracker counts consecutive failed checks. It asks for an alert once
+// AlertAfter checks in a row failed, or right away if credentials were
+// rejected, and for a recovery message on the first success after that.
+// Zero AlertAfter disables alerts.
+type Tracker struct {
+	backoff    Backoff
+	alertAfter int
+	failures   int
+	isAlerted  bool
+	random     func() float64
+}
+
+func NewTracker(backoff Backoff, alertAfter int) *Tracker {
+	return &Tracker{
+		backoff:    backoff.withDefaults(),
+		alertAfter: alertAfter,
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())).Float64,
+	}
+}
+
+// Configure changes the backoff and the alert threshold keeping the count
+// of failures, e.g. on config reload.
+func (t *Tracker) Configure(backoff Backoff, alertAfter int) {
+	t.backoff = backoff.withDefaults()
+	t.alertAfter = alertAfter
+}
+
+// Failed records the failure and returns the delay before the next attempt
+// and whether an alert should be sent.
+func (t *Tracker) Failed(err error) (time.Duration, bool) {
+	t.failures++
+
+	isAlertDue := KindOf(err) == KindAuth || (t.alertAfter > 0 && t.failures >= t.alertAfter)
+	shouldAlert := isAlertDue && !t.isAlerted
+	if shouldAlert {
+		t.isAlerted = true
+	}
+
+	return t.backoff.delay(t.failures, t.random()), shouldAlert
+}
+
+// Succeeded resets the failures and returns whether the recovery should be
+// announced.
+func (t *Tracker) Succeeded() bool {
+	wasAlerted := t.isAlerted
+
+	t.failures = 0
+	t.isAlerted = false
+
+	return wasAlerted
+}
+
+func (t *Tracker) Failures() int {
+	return t.failures
+}
@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// The keys of the fields shared by every package, so the records of a check
+// or a course can be found by them.
+const (
+	KeyCycle    = "cycle"
+	KeyUser     = "user"
+	KeyCourseID = "course_id"
+	KeyCourse   = "course"
+	KeyFunction = "wsfunction"
+	KeyDuration = "duration"
+	KeyService  = "service"
+	KeyError    = "error"
+)
+
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat reads the log format, text is the default.
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(value)) {
+	case "", FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q, expected text or json", value)
+	}
+}
+
+// ParseLevel reads a level like "debug" or "warn", info is the default.
+func ParseLevel(value string) (slog.Level, error) {
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	err := level.UnmarshalText([]byte(value))
+	if err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", value)
+	}
+
+	return level, nil
+}
+
+// New makes a logger that writes the records of level and up to w, one
+// line each. The fields added to a context by With are logged by the
+// Context methods, e.g. InfoContext.
+func New(w io.Writer, format Format, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: shortSource,
+	}
+
+	var handler slog.Handler = slog.NewTextHandler(w, options)
+	if format == FormatJSON {
+		handler = slog.NewJSONHandler(w, options)
+	}
+
+	return slog.New(contextHandler{handler})
+}
+
+// Discard makes a logger that drops every record, e.g. for tests.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// Err is the error field.
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.String(KeyError, "")
+	}
+
+	return slog.String(KeyError, err.Error())
+}
+
+type fieldsKey struct{}
+
+// With returns ctx with fields added to the records logged with it, args
+// are key value pairs like the ones of slog.Logger.With.
+func With(ctx context.Context, args ...any) context.Context {
+	fields := append(fieldsOf(ctx), args...)
+
+	return context.WithValue(ctx, fieldsKey{}, fields)
+}
+
+// WithContext returns logger with the fields of ctx, for code that logs
+// without a context.
+func WithContext(logger *slog.Logger, ctx context.Context) *slog.Logger {
+	fields := fieldsOf(ctx)
+	if len(fields) == 0 {
+		return logger
+	}
+
+	return logger.With(fields...)
+}
+
+func fieldsOf(ctx context.Context) []any {
+	fields, _ := ctx.Value(fieldsKey{}).([]any)
+
+	// a copy, so appending to it does not change the fields of ctx
+	return append([]any{}, fields...)
+}
+
+// contextHandler adds the fields of the context to the records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := fieldsOf(ctx)
+	if len(fields) > 0 {
+		record = record.Clone()
+		record.Add(fields...)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// shortSource logs the file name and the line instead of the whole path,
+// like log.Lshortfile.
+func shortSource(groups []string, attr slog.Attr) slog.Attr {
+	source, ok := attr.Value.Any().(*slog.Source)
+	if attr.Key != slog.SourceKey || !ok {
+		return attr
+	}
+
+	return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+}
@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	t.Run("json with context fields", func(t *testing.T) {
+		out := bytes.Buffer{}
+		logger := New(&out, FormatJSON, slog.LevelInfo)
+		ctx := With(context.Background(), KeyCycle, 3, KeyUser, "student")
+
+		logger.InfoContext(With(ctx, KeyCourseID, 42), "fetched", KeyFunction, "core_webservice_get_site_info")
+		logger.WarnContext(ctx, "failed", Err(errors.New("boom")))
+
+		lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		assert.Len(t, lines, 2)
+
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal(lines[0], &record))
+		assert.Equal(t, "INFO", record["level"])
+		assert.Equal(t, "fetched", record["msg"])
+		assert.Equal(t, float64(3), record[KeyCycle])
+		assert.Equal(t, "student", record[KeyUser])
+		assert.Equal(t, float64(42), record[KeyCourseID])
+		assert.Equal(t, "core_webservice_get_site_info", record[KeyFunction])
+		assert.Contains(t, record["source"], "logging_test.go:")
+
+		record = map[string]any{}
+		assert.NoError(t, json.Unmarshal(lines[1], &record))
+		assert.Equal(t, "boom", record[KeyError])
+		assert.NotContains(t, record, KeyCourseID)
+	})
+
+	t.Run("fields of context on logger", func(t *testing.T) {
+		out := bytes.Buffer{}
+		ctx := With(context.Background(), KeyCycle, 7)
+
+		WithContext(New(&out, FormatText, slog.LevelInfo), ctx).Info("saved")
+
+		assert.Contains(t, out.String(), "cycle=7")
+		assert.Contains(t, out.String(), "msg=saved")
+	})
+
+	t.Run("records below level dropped", func(t *testing.T) {
+		out := bytes.Buffer{}
+		logger := New(&out, FormatText, slog.LevelWarn)
+
+		logger.Info("hidden")
+		logger.Debug("hidden")
+		logger.Warn("shown")
+
+		assert.NotContains(t, out.String(), "hidden")
+		assert.Contains(t, out.String(), "shown")
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("level", func(t *testing.T) {
+		cases := map[string]slog.Level{
+			"":      slog.LevelInfo,
+			"debug": slog.LevelDebug,
+			"WARN":  slog.LevelWarn,
+			"error": slog.LevelError,
+		}
+		for value, expected := range cases {
+			level, err := ParseLevel(value)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, level)
+		}
+
+		_, err := ParseLevel("verbose")
+		assert.Error(t, err)
+	})
+
+	t.Run("format", func(t *testing.T) {
+		format, err := ParseFormat("")
+		assert.NoError(t, err)
+		assert.Equal(t, FormatText, format)
+
+		format, err = ParseFormat("JSON")
+		assert.NoError(t, err)
+		assert.Equal(t, FormatJSON, format)
+
+		_, err = ParseFormat("xml")
+		assert.Error(t, err)
+	})
+}
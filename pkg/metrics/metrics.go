@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+const namespace = "moodle_scrapper"
+
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// The metrics are kept for the whole process, so they outlive config
+// reloads.
+var (
+	registry = prometheus.NewRegistry()
+
+	checks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "checks_total",
+		Help:      "Checks by result, failed checks by the reason of the failure.",
+	}, []string{"result", "reason"})
+
+	checkDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "check_duration_seconds",
+		Help:      "Duration of the checks.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+	})
+
+	lastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful check.",
+	})
+
+	moodleCalls = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "moodle_request_duration_seconds",
+		Help:      "Duration of the moodle API calls by wsfunction, each retry is a call.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"wsfunction", "result"})
+
+	tokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "token_refreshes_total",
+		Help:      "Logins to get a new moodle token.",
+	}, []string{"result"})
+
+	changes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "changes_total",
+		Help:      "Detected grade changes by type: create, update, remove or total.",
+	}, []string{"type"})
+
+	messages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Sent messages by service and kind, like updates or alert.",
+	}, []string{"service", "kind"})
+
+	messageFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "message_failures_total",
+		Help:      "Messages that failed to be sent by service and kind.",
+	}, []string{"service", "kind"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		checks,
+		checkDuration,
+		lastSuccess,
+		moodleCalls,
+		tokenRefreshes,
+		changes,
+		messages,
+		messageFailures,
+	)
+}
+
+// CheckSucceeded counts a successful check, finished now.
+func CheckSucceeded(duration time.Duration) {
+	checks.WithLabelValues(resultSuccess, "").Inc()
+	checkDuration.Observe(duration.Seconds())
+	lastSuccess.SetToCurrentTime()
+}
+
+// CheckFailed counts a failed check, reason is the kind of the failure.
+func CheckFailed(reason string, duration time.Duration) {
+	checks.WithLabelValues(resultFailure, reason).Inc()
+	checkDuration.Observe(duration.Seconds())
+}
+
+// MoodleCalled observes a call of wsfunction, err is the error it failed
+// with.
+func MoodleCalled(wsfunction string, duration time.Duration, err error) {
+	moodleCalls.WithLabelValues(wsfunction, result(err)).Observe(duration.Seconds())
+}
+
+// TokenRefreshed counts a login for a new token, err is the error it failed
+// with.
+func TokenRefreshed(err error) {
+	tokenRefreshes.WithLabelValues(result(err)).Inc()
+}
+
+// ChangesDetected counts the changes of a check, counts are by the type of
+// the change.
+func ChangesDetected(counts map[string]int) {
+	for changeType, count := range counts {
+		changes.WithLabelValues(changeType).Add(float64(count))
+	}
+}
+
+// MessageSent counts a message of kind sent through service, err is the
+// error sending failed with.
+func MessageSent(service, kind string, err error) {
+	if err != nil {
+		messageFailures.WithLabelValues(service, kind).Inc()
+		return
+	}
+
+	messages.WithLabelValues(service, kind).Inc()
+}
+
+func result(err error) string {
+	if err != nil {
+		return resultFailure
+	}
+
+	return resultSuccess
+}
+
+// Handler serves the metrics in the prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Serve serves /metrics on addr until ctx is done. Only listening is
+// waited for, so a taken address is returned right away.
+func Serve(ctx context.Context, addr string, log *slog.Logger) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to serve metrics: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("failed to serve metrics", logging.Err(err))
+		}
+	}()
+
+	log.Info("serving metrics", "addr", listener.Addr().String())
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("recorded metrics exposed", func(t *testing.T) {
+		CheckSucceeded(2 * time.Second)
+		CheckFailed("moodle down", time.Second)
+		MoodleCalled("core_webservice_get_site_info", 100*time.Millisecond, nil)
+		MoodleCalled("core_webservice_get_site_info", time.Second, errors.New("timeout"))
+		TokenRefreshed(nil)
+		ChangesDetected(map[string]int{"update": 2, "total": 1})
+		MessageSent("telegram", "updates", nil)
+		MessageSent("telegram", "alert", errors.New("blocked"))
+
+		response := httptest.NewRecorder()
+		Handler().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		body := response.Body.String()
+
+		for _, line := range []string{
+			`moodle_scrapper_checks_total{reason="",result="success"} 1`,
+			`moodle_scrapper_checks_total{reason="moodle down",result="failure"} 1`,
+			`moodle_scrapper_check_duration_seconds_count 2`,
+			`moodle_scrapper_moodle_request_duration_seconds_count{result="success",wsfunction="core_webservice_get_site_info"} 1`,
+			`moodle_scrapper_moodle_request_duration_seconds_count{result="failure",wsfunction="core_webservice_get_site_info"} 1`,
+			`moodle_scrapper_token_refreshes_total{result="success"} 1`,
+			`moodle_scrapper_changes_total{type="update"} 2`,
+			`moodle_scrapper_changes_total{type="total"} 1`,
+			`moodle_scrapper_messages_sent_total{kind="updates",service="telegram"} 1`,
+			`moodle_scrapper_message_failures_total{kind="alert",service="telegram"} 1`,
+			`moodle_scrapper_last_success_timestamp_seconds `,
+		} {
+			assert.Contains(t, body, line)
+		}
+	})
+
+	t.Run("served until done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// a free port
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		err = Serve(ctx, addr, logging.Discard())
+		assert.NoError(t, err)
+
+		response, err := http.Get("http://" + addr + "/metrics")
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Contains(t, string(body), "moodle_scrapper_checks_total")
+
+		err = Serve(ctx, addr, logging.Discard())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,279 @@
+package moodle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+	"golang.org/x/time/rate"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/metrics"
+)
+
+const defaultMoodleURL = "https://moodle.innopolis.university"
+
+var (
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrAccessException     = errors.New("access exception")
+	ErrServiceNotAvailable = errors.New("service not available")
+)
+
+// APIError is the error envelope moodle answers with instead of the
+// function result. It matches ErrInvalidToken, ErrAccessException and
+// ErrServiceNotAvailable by errorcode.
+type APIError struct {
+	Function  string
+	Exception string
+	ErrorCode string
+	Message   string
+}
+
+func (e APIError) Error() string {
+	return fmt.Sprintf("moodle %s failed: %s (%s)", e.Function, e.Message, e.ErrorCode)
+}
+
+func (e APIError) Is(target error) bool {
+	switch target {
+	case ErrInvalidToken:
+		return e.ErrorCode == "invalidtoken"
+	case ErrAccessException:
+		return e.ErrorCode == "accessexception"
+	case ErrServiceNotAvailable:
+		return e.ErrorCode == "servicenotavailable"
+	default:
+		return false
+	}
+}
+
+type StatusError struct {
+	Function   string
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("moodle %s failed: unexpected status %d", e.Function, e.StatusCode)
+}
+
+type ClientConfig struct {
+	BaseURL           string
+	Timeout           time.Duration
+	Retries           int
+	RetryDelay        time.Duration
+	RequestsPerSecond float64
+}
+
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		BaseURL:           defaultMoodleURL,
+		Timeout:           10 * time.Second,
+		Retries:           2,
+		RetryDelay:        time.Second,
+		RequestsPerSecond: 5,
+	}
+}
+
+func (cfg ClientConfig) withDefaults() ClientConfig {
+	defaultCfg := DefaultClientConfig()
+
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultCfg.BaseURL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultCfg.Timeout
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = defaultCfg.RetryDelay
+	}
+	if cfg.RequestsPerSecond <= 0 {
+		cfg.RequestsPerSecond = defaultCfg.RequestsPerSecond
+	}
+
+	return cfg
+}
+
+// Client calls moodle web service functions. Clients made by WithToken share
+// connections and the rate limit with the client they were made from.
+type Client struct {
+	httpClient *http.Client
+	limiter    *rate.Limiter
+	cfg        ClientConfig
+	token      MoodleToken
+	log        *slog.Logger
+}
+
+func NewClient(cfg ClientConfig, log *slog.Logger) *Client {
+	cfg = cfg.withDefaults()
+
+	return &Client{
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		limiter:    rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), 1),
+		cfg:        cfg,
+		log:        log,
+	}
+}
+
+func (c *Client) WithToken(token MoodleToken) *Client {
+	clientWithToken := *c
+	clientWithToken.token = token
+
+	return &clientWithToken
+}
+
+// withLog returns a client that logs to log, e.g. with the fields of a
+// check.
+func (c *Client) withLog(log *slog.Logger) *Client {
+	clientWithLog := *c
+	clientWithLog.log = log
+
+	return &clientWithLog
+}
+
+// Call sends the web service function and returns its JSON result. Network
+// failures and 5xx responses are retried, moodle errors are not.
+func (c *Client) Call(
+	ctx context.Context,
+	wsfunction string,
+	args map[string]string,
+) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt <= c.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			c.log.WarnContext(ctx, "retrying moodle call", logging.KeyFunction, wsfunction, "attempt", attempt, logging.Err(err))
+
+			err = c.sleep(ctx, time.Duration(attempt)*c.cfg.RetryDelay)
+			if err != nil {
+				return nil, fmt.Errorf("failed to make a request to moodle: %w", err)
+			}
+		}
+
+		start := time.Now()
+		var body []byte
+		body, err = c.call(ctx, wsfunction, args)
+		duration := time.Since(start)
+		metrics.MoodleCalled(wsfunction, duration, err)
+		if err == nil {
+			c.log.DebugContext(ctx, "called moodle", logging.KeyFunction, wsfunction, logging.KeyDuration, duration)
+			return body, nil
+		}
+		c.log.DebugContext(ctx, "moodle call failed", logging.KeyFunction, wsfunction, logging.KeyDuration, duration, logging.Err(err))
+
+		if !c.isTransient(ctx, err) {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("failed to make a request to moodle: %w", err)
+}
+
+func (c *Client) call(
+	ctx context.Context,
+	wsfunction string,
+	args map[string]string,
+) ([]byte, error) {
+	err := c.limiter.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	moodleURL := c.cfg.BaseURL + "/webservice/rest/server.php?moodlewsrestformat=json&wsfunction=" + wsfunction
+	data := url.Values{
+		"moodlewssettingfilter":  {"True"},
+		"moodlewssettingfileurl": {"False"},
+		"wsfunction":             {wsfunction},
+		"wstoken":                {string(c.token)},
+	}
+	for k, v := range args {
+		data.Add(k, v)
+	}
+
+	moodleReq, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		moodleURL,
+		strings.NewReader(data.Encode()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	moodleReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := c.httpClient.Do(moodleReq)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, StatusError{Function: wsfunction, StatusCode: response.StatusCode}
+	}
+
+	apiErr, ok := c.parseAPIError(wsfunction, body)
+	if ok {
+		return nil, apiErr
+	}
+
+	return body, nil
+}
+
+func (c *Client) parseAPIError(wsfunction string, body []byte) (APIError, bool) {
+	envelope := gjson.ParseBytes(body)
+	if !envelope.IsObject() || !envelope.Get("exception").Exists() {
+		return APIError{}, false
+	}
+
+	apiErr := APIError{
+		Function:  wsfunction,
+		Exception: envelope.Get("exception").String(),
+		ErrorCode: envelope.Get("errorcode").String(),
+		Message:   envelope.Get("message").String(),
+	}
+
+	return apiErr, true
+}
+
+func (c *Client) isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var statusErr StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+func (c *Client) sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
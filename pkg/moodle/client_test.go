@@ -0,0 +1,93 @@
+package moodle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+func newTestClient(handler http.HandlerFunc) (*Client, func()) {
+	server := httptest.NewServer(handler)
+
+	client := NewClient(ClientConfig{
+		BaseURL:           server.URL,
+		Retries:           2,
+		RetryDelay:        time.Millisecond,
+		RequestsPerSecond: 1000,
+	}, logging.Discard())
+
+	return client.WithToken("token"), server.Close
+}
+
+func TestClientCall(t *testing.T) {
+	t.Run("returns the result", func(t *testing.T) {
+		client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			assert.NoError(t, r.ParseForm())
+			assert.Equal(t, "token", r.PostForm.Get("wstoken"))
+			assert.Equal(t, "7", r.PostForm.Get("userid"))
+			w.Write([]byte(`[{"id": 1}]`))
+		})
+		defer closeServer()
+
+		body, err := client.Call(context.Background(), "core_enrol_get_users_courses", map[string]string{"userid": "7"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, `[{"id": 1}]`, string(body))
+	})
+
+	t.Run("decodes the error envelope", func(t *testing.T) {
+		client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"exception": "moodle_exception", "errorcode": "invalidtoken", "message": "Invalid token - token not found"}`))
+		})
+		defer closeServer()
+
+		_, err := client.Call(context.Background(), "core_webservice_get_site_info", nil)
+
+		var apiErr APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, "moodle_exception", apiErr.Exception)
+		assert.True(t, errors.Is(err, ErrInvalidToken))
+		assert.False(t, errors.Is(err, ErrAccessException))
+	})
+
+	t.Run("retries server errors", func(t *testing.T) {
+		var requests int32
+		client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(`{}`))
+		})
+		defer closeServer()
+
+		_, err := client.Call(context.Background(), "core_webservice_get_site_info", nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var requests int32
+		client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.WriteHeader(http.StatusForbidden)
+		})
+		defer closeServer()
+
+		_, err := client.Call(context.Background(), "core_webservice_get_site_info", nil)
+
+		var statusErr StatusError
+		assert.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	})
+}
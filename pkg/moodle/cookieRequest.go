@@ -1,31 +1,40 @@
 package moodle
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
-	"os"
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/dump"
 )
 
 type MoodleToken string
 
+// ErrWrongCredentials means SSO did not accept the login and password,
+// retrying will not help until they are changed.
+var ErrWrongCredentials = errors.New("wrong login or password")
+
 type cookieRequest struct {
 	client           *http.Client
 	clientNoRedirect *http.Client
 	credentials      Credentials
-	log              *log.Logger
+	dumps            dump.Dumps
+	log              *slog.Logger
 }
 
 func newCookieRequest(
-	credentialsPath string,
-	logger *log.Logger,
+	credentials Credentials,
+	dumps dump.Dumps,
+	logger *slog.Logger,
 ) (cookieRequest, error) {
 	cookiejar, err := cookiejar.New(nil)
 	if err != nil {
@@ -43,29 +52,27 @@ func newCookieRequest(
 		},
 	}
 
-	credentials := newCredentials(credentialsPath)
-
-	return cookieRequest{client, clientNoRedirect, credentials, logger}, nil
+	return cookieRequest{client, clientNoRedirect, credentials, dumps, logger}, nil
 }
 
-func (reqManager *cookieRequest) requestNewTokens() (MoodleToken, error) {
-	reqManager.log.Println("getting new token...")
-	ssoURL, err := reqManager.getSsoURL()
+func (reqManager *cookieRequest) requestNewTokens(ctx context.Context) (MoodleToken, error) {
+	reqManager.log.InfoContext(ctx, "getting new token")
+	ssoURL, err := reqManager.getSsoURL(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to get tokens: %v", err)
 	}
 
-	code, state, err := reqManager.loginInSSO(ssoURL)
+	code, state, err := reqManager.loginInSSO(ctx, ssoURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to get tokens: %v", err)
+		return "", fmt.Errorf("failed to get tokens: %w", err)
 	}
 
-	token, err := reqManager.getMoodleTokens(code, state)
+	token, err := reqManager.getMoodleTokens(ctx, code, state)
 	if err != nil {
 		return "", fmt.Errorf("failed to get tokens: %v", err)
 	}
 
-	reqManager.log.Println("saving tokens...")
+	reqManager.log.InfoContext(ctx, "saving new token")
 	err = reqManager.credentials.save(string(token))
 	if err != nil {
 		return "", fmt.Errorf("failed to get tokens: %v", err)
@@ -74,8 +81,8 @@ func (reqManager *cookieRequest) requestNewTokens() (MoodleToken, error) {
 	return token, nil
 }
 
-func (reqManager *cookieRequest) getMoodleTokens(code, state string) (MoodleToken, error) {
-	response, err := reqManager.sendMoodleCookieRequests(code, state)
+func (reqManager *cookieRequest) getMoodleTokens(ctx context.Context, code, state string) (MoodleToken, error) {
+	response, err := reqManager.sendMoodleCookieRequests(ctx, code, state)
 	if err != nil {
 		return "", fmt.Errorf("failed to get tokens from moodle: %v", err)
 	}
@@ -95,6 +102,7 @@ func restrictRedirect(req *http.Request, mix []*http.Request) error {
 }
 
 func (reqManager *cookieRequest) sendMoodleCookieRequests(
+	ctx context.Context,
 	code, state string,
 ) (_ *http.Response, err error) {
 	defer func() {
@@ -107,7 +115,8 @@ func (reqManager *cookieRequest) sendMoodleCookieRequests(
 		"code":  {code},
 		"state": {state},
 	}
-	moodlePostRequest, err := http.NewRequest(
+	moodlePostRequest, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodPost,
 		"https://moodle.innopolis.university:443/admin/oauth2callback.php",
 		strings.NewReader(moodleData.Encode()),
@@ -128,7 +137,7 @@ func (reqManager *cookieRequest) sendMoodleCookieRequests(
 	if err != nil {
 		return nil, err
 	}
-	launchReq, err := http.NewRequest(http.MethodGet, launchURL.String(), nil)
+	launchReq, err := http.NewRequestWithContext(ctx, http.MethodGet, launchURL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +147,7 @@ func (reqManager *cookieRequest) sendMoodleCookieRequests(
 	}
 	defer launchRes.Body.Close()
 
-	launchReq2, err := http.NewRequest(http.MethodGet, launchRes.Header.Get("Location"), nil)
+	launchReq2, err := http.NewRequestWithContext(ctx, http.MethodGet, launchRes.Header.Get("Location"), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -173,11 +182,12 @@ func (reqManager *cookieRequest) extractTokens(
 }
 
 func (reqManager *cookieRequest) loginInSSO(
+	ctx context.Context,
 	ssoURL string,
 ) (code string, state string, err error) {
 	credentials, err := reqManager.credentials.get()
 	if err != nil {
-		return "", "", fmt.Errorf("failed to login in SSO: %v", err)
+		return "", "", fmt.Errorf("failed to login in SSO: %w", err)
 	}
 
 	ssoData := url.Values{
@@ -186,35 +196,52 @@ func (reqManager *cookieRequest) loginInSSO(
 		"Kmsi":       {"true"},
 		"AuthMethod": {"FormsAuthentication"},
 	}
-	ssoResponse, err := reqManager.sendSSOPostRequest(ssoURL, ssoData)
+	ssoResponse, err := reqManager.sendSSOPostRequest(ctx, ssoURL, ssoData)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to login in SSO: %v", err)
 	}
 
-	codeRegex := regexp.MustCompile(`(?:name="code" value=")(.+?)" />`)
-	codeMatches := codeRegex.FindStringSubmatch(ssoResponse)
-	if len(codeMatches) < 2 {
-		reqManager.saveResponseToFile([]byte(ssoResponse))
-		return "", "", fmt.Errorf("failed to login in SSO: wrong login or password")
+	code, state, err = parseSSOResponse(ssoResponse)
+	if err != nil {
+		reqManager.dumps.Save("sso-response", ".html", []byte(ssoResponse))
+		return "", "", fmt.Errorf("failed to login in SSO: %w", err)
+	}
+
+	return code, state, nil
+}
+
+var (
+	ssoCodeRegex  = regexp.MustCompile(`(?:name="code" value=")(.+?)" />`)
+	ssoStateRegex = regexp.MustCompile(`(?:name="state" value=")(.+?)" />`)
+	// the login form always has the error element, it has text only after
+	// a failed login
+	ssoErrorRegex = regexp.MustCompile(`id="errorText"[^>]*>\s*([^<\s][^<]*)<`)
+)
+
+// parseSSOResponse returns the code and the state of a successful login.
+// Only the login error of the SSO page is ErrWrongCredentials, any other
+// page is taken for the SSO being unavailable.
+func parseSSOResponse(ssoResponse string) (code string, state string, err error) {
+	errorMatches := ssoErrorRegex.FindStringSubmatch(ssoResponse)
+	if len(errorMatches) == 2 {
+		return "", "", fmt.Errorf("%q: %w", strings.TrimSpace(errorMatches[1]), ErrWrongCredentials)
 	}
-	code = codeMatches[1]
 
-	stateRegex := regexp.MustCompile(`(?:name="state" value=")(.+?)" />`)
-	stateMatches := stateRegex.FindStringSubmatch(ssoResponse)
-	if len(stateMatches) < 2 {
-		reqManager.saveResponseToFile([]byte(ssoResponse))
-		return "", "", fmt.Errorf("failed to login in SSO: wrong login or password")
+	codeMatches := ssoCodeRegex.FindStringSubmatch(ssoResponse)
+	stateMatches := ssoStateRegex.FindStringSubmatch(ssoResponse)
+	if len(codeMatches) < 2 || len(stateMatches) < 2 {
+		return "", "", fmt.Errorf("no code and state in SSO response")
 	}
-	state = stateMatches[1]
 
-	return code, state, err
+	return codeMatches[1], stateMatches[1], nil
 }
 
 func (reqManager *cookieRequest) sendSSOPostRequest(
+	ctx context.Context,
 	ssoURL string,
 	ssoData url.Values,
 ) (string, error) {
-	ssoReq, err := http.NewRequest(http.MethodPost, ssoURL, strings.NewReader(ssoData.Encode()))
+	ssoReq, err := http.NewRequestWithContext(ctx, http.MethodPost, ssoURL, strings.NewReader(ssoData.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("failed to send SSO POST request: %v", err)
 	}
@@ -232,13 +259,13 @@ func (reqManager *cookieRequest) sendSSOPostRequest(
 	return string(ssoResBody), nil
 }
 
-func (reqManager *cookieRequest) getSsoURL() (string, error) {
-	loginUrl, err := reqManager.getMoodleLoginButtonURL()
+func (reqManager *cookieRequest) getSsoURL(ctx context.Context) (string, error) {
+	loginUrl, err := reqManager.getMoodleLoginButtonURL(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to get SSO URL: %v", err)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, loginUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loginUrl, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to get SSO URL: %v", err)
 	}
@@ -254,8 +281,9 @@ func (reqManager *cookieRequest) getSsoURL() (string, error) {
 	return ssoURL, nil
 }
 
-func (reqManager *cookieRequest) getMoodleLoginButtonURL() (string, error) {
-	req, err := http.NewRequest(
+func (reqManager *cookieRequest) getMoodleLoginButtonURL(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodGet,
 		"https://moodle.innopolis.university/admin/tool/mobile/launch.php?service=moodle_mobile_app&passport=1",
 		nil,
@@ -288,19 +316,3 @@ func (reqManager *cookieRequest) getMoodleLoginButtonURL() (string, error) {
 
 	return loginUrl, nil
 }
-
-func (reqManager *cookieRequest) saveResponseToFile(response []byte) {
-	filepath := "./wrong_response.html"
-
-	reqManager.log.Printf("saving bad response to: %q\n", filepath)
-	f, err := os.OpenFile(filepath, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
-	if err != nil {
-		panic(err)
-	}
-	defer f.Close()
-
-	_, err = f.Write(response)
-	if err != nil {
-		panic(err)
-	}
-}
@@ -0,0 +1,46 @@
+package moodle
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSSOResponse(t *testing.T) {
+	t.Run("code and state", func(t *testing.T) {
+		code, state, err := parseSSOResponse(`<form method="POST">
+			<input type="hidden" name="code" value="abc" />
+			<input type="hidden" name="state" value="xyz" />
+		</form>`)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", code)
+		assert.Equal(t, "xyz", state)
+	})
+
+	t.Run("login error", func(t *testing.T) {
+		_, _, err := parseSSOResponse(`<form id="loginForm">
+			<span id="errorText" for="" aria-live="assertive" role="alert">Incorrect user ID or password. Type the correct user ID and password, and try again.</span>
+		</form>`)
+
+		assert.True(t, errors.Is(err, ErrWrongCredentials))
+		assert.Contains(t, err.Error(), "Incorrect user ID or password")
+	})
+
+	t.Run("login form without error", func(t *testing.T) {
+		_, _, err := parseSSOResponse(`<form id="loginForm">
+			<span id="errorText" for="" aria-live="assertive" role="alert"></span>
+		</form>`)
+
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrWrongCredentials))
+	})
+
+	t.Run("unexpected page", func(t *testing.T) {
+		_, _, err := parseSSOResponse(`<html><body>Service Unavailable</body></html>`)
+
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrWrongCredentials))
+	})
+}
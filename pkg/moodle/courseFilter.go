@@ -0,0 +1,255 @@
+package moodle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+const (
+	ClassificationInProgress = "inprogress"
+	ClassificationAll        = "all"
+)
+
+// CourseFilter selects the courses to poll. Classification "inprogress"
+// keeps courses moodle classifies as in progress plus the ones that ended
+// less than GracePeriod ago. Include and Exclude hold course IDs or regular
+// expressions matched against the course name, Include overrides the
+// classification and Exclude overrides everything.
+type CourseFilter struct {
+	Classification string
+	GracePeriod    time.Duration
+	Include        []string
+	Exclude        []string
+}
+
+type courseMatcher struct {
+	ids      map[int]bool
+	patterns []*regexp.Regexp
+}
+
+type timelineCoursesJSON struct {
+	Courses []struct {
+		ID int `json:"id"`
+	} `json:"courses"`
+}
+
+// GetTrackedCourses returns the courses selected by filter with their grades.
+// Enrolled courses that are no longer tracked keep their version of the
+// snapshot given to WithSnapshot, marked as Untracked, so they are not
+// reported as removed.
+func (moodle Moodle) GetTrackedCourses(ctx context.Context, filter CourseFilter) ([]Course, error) {
+	courses, err := moodle.getEnrolledCourses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tracked courses: %w", err)
+	}
+
+	inProgress := map[int]bool{}
+	if filter.Classification != ClassificationAll {
+		inProgress, err = moodle.getInProgressCourseIDs(ctx, courses)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tracked courses: %w", err)
+		}
+	}
+
+	trackedCourses, err := filterCourses(courses, inProgress, filter, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tracked courses: %w", err)
+	}
+
+	err = moodle.fetchCoursesGrades(ctx, trackedCourses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tracked courses: %w", err)
+	}
+
+	return append(trackedCourses, moodle.previousUntracked(courses, trackedCourses)...), nil
+}
+
+func (moodle Moodle) previousUntracked(courses, trackedCourses []Course) []Course {
+	isTracked := make(map[int]bool, len(trackedCourses))
+	for _, course := range trackedCourses {
+		isTracked[course.ID] = true
+	}
+
+	untracked := []Course{}
+	for _, course := range courses {
+		previousCourse, ok := moodle.previous[course.ID]
+		if isTracked[course.ID] || !ok {
+			continue
+		}
+
+		previousCourse.Untracked = true
+		untracked = append(untracked, previousCourse)
+	}
+
+	return untracked
+}
+
+// getInProgressCourseIDs asks moodle for the timeline classification and
+// falls back to course dates if the function is not available.
+func (moodle Moodle) getInProgressCourseIDs(ctx context.Context, courses []Course) (map[int]bool, error) {
+	data := map[string]string{
+		"classification": ClassificationInProgress,
+		"limit":          "0",
+		"offset":         "0",
+	}
+
+	timelineJSON, err := moodle.MoodleAPIRequest(
+		ctx,
+		"core_course_get_enrolled_courses_by_timeline_classification",
+		data,
+	)
+	if err != nil {
+		moodle.log.WarnContext(ctx, "failed to get in progress courses, using course dates", logging.Err(err))
+		return inProgressByDates(courses, time.Now()), nil
+	}
+
+	var timelineCourses timelineCoursesJSON
+	err = json.Unmarshal(timelineJSON, &timelineCourses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse in progress courses: %w", err)
+	}
+
+	inProgress := make(map[int]bool, len(timelineCourses.Courses))
+	for _, course := range timelineCourses.Courses {
+		inProgress[course.ID] = true
+	}
+
+	return inProgress, nil
+}
+
+func inProgressByDates(courses []Course, now time.Time) map[int]bool {
+	inProgress := map[int]bool{}
+	for _, course := range courses {
+		hasStarted := course.Startdate <= now.Unix()
+		hasEnded := course.Enddate != 0 && course.Enddate <= now.Unix()
+
+		if hasStarted && !hasEnded {
+			inProgress[course.ID] = true
+		}
+	}
+
+	return inProgress
+}
+
+// Validate checks the classification and the patterns of Include and
+// Exclude.
+func (filter CourseFilter) Validate() []error {
+	problems := []error{}
+
+	isKnown := filter.Classification == "" ||
+		filter.Classification == ClassificationInProgress ||
+		filter.Classification == ClassificationAll
+	if !isKnown {
+		problems = append(problems, fmt.Errorf(
+			"unknown course classification %q, expected %q or %q",
+			filter.Classification,
+			ClassificationInProgress,
+			ClassificationAll,
+		))
+	}
+
+	_, err := newCourseMatcher(filter.Include)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("bad included courses: %v", err))
+	}
+
+	_, err = newCourseMatcher(filter.Exclude)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("bad excluded courses: %v", err))
+	}
+
+	return problems
+}
+
+func filterCourses(
+	courses []Course,
+	inProgress map[int]bool,
+	filter CourseFilter,
+	now time.Time,
+) ([]Course, error) {
+	included, err := newCourseMatcher(filter.Include)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded, err := newCourseMatcher(filter.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	filteredCourses := []Course{}
+	for _, course := range courses {
+		if excluded.matches(course) {
+			continue
+		}
+
+		isTracked := !course.Hidden && isCourseInTime(course, inProgress, filter, now)
+		if !isTracked && !included.matches(course) {
+			continue
+		}
+
+		filteredCourses = append(filteredCourses, course)
+	}
+
+	return filteredCourses, nil
+}
+
+func isCourseInTime(
+	course Course,
+	inProgress map[int]bool,
+	filter CourseFilter,
+	now time.Time,
+) bool {
+	if filter.Classification == ClassificationAll || inProgress[course.ID] {
+		return true
+	}
+
+	hasEnded := course.Enddate != 0 && course.Enddate <= now.Unix()
+	if !hasEnded {
+		return false
+	}
+
+	gradesDeadline := time.Unix(course.Enddate, 0).Add(filter.GracePeriod)
+
+	return now.Before(gradesDeadline)
+}
+
+func newCourseMatcher(rules []string) (courseMatcher, error) {
+	matcher := courseMatcher{ids: map[int]bool{}}
+
+	for _, rule := range rules {
+		id, err := strconv.Atoi(rule)
+		if err == nil {
+			matcher.ids[id] = true
+			continue
+		}
+
+		pattern, err := regexp.Compile(rule)
+		if err != nil {
+			return courseMatcher{}, fmt.Errorf("bad course pattern %q: %v", rule, err)
+		}
+		matcher.patterns = append(matcher.patterns, pattern)
+	}
+
+	return matcher, nil
+}
+
+func (matcher courseMatcher) matches(course Course) bool {
+	if matcher.ids[course.ID] {
+		return true
+	}
+
+	for _, pattern := range matcher.patterns {
+		if pattern.MatchString(course.Fullname) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,139 @@
+package moodle
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+func TestFilterCourses(t *testing.T) {
+	now := time.Date(2023, time.May, 20, 0, 0, 0, 0, time.UTC)
+	day := int64(24 * time.Hour / time.Second)
+
+	courses := []Course{
+		{ID: 1, Fullname: "[S23] AGLA II", Startdate: now.Unix() - 90*day, Enddate: now.Unix() + 10*day},
+		{ID: 2, Fullname: "[F22] AGLA I", Startdate: now.Unix() - 300*day, Enddate: now.Unix() - 120*day},
+		{ID: 3, Fullname: "[S23] Physics", Startdate: now.Unix() - 120*day, Enddate: now.Unix() - 5*day},
+		{ID: 4, Fullname: "[S23] Hidden", Startdate: now.Unix() - 90*day, Hidden: true},
+		{ID: 5, Fullname: "Announcements"},
+	}
+	inProgress := map[int]bool{1: true, 4: true, 5: true}
+
+	courseIDs := func(courses []Course) []int {
+		ids := []int{}
+		for _, course := range courses {
+			ids = append(ids, course.ID)
+		}
+		return ids
+	}
+
+	t.Run("in progress with grace period", func(t *testing.T) {
+		filter := CourseFilter{Classification: ClassificationInProgress, GracePeriod: 30 * 24 * time.Hour}
+
+		filtered, err := filterCourses(courses, inProgress, filter, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 3, 5}, courseIDs(filtered))
+	})
+
+	t.Run("include and exclude", func(t *testing.T) {
+		filter := CourseFilter{
+			Classification: ClassificationInProgress,
+			Include:        []string{"2"},
+			Exclude:        []string{"^Announcements$"},
+		}
+
+		filtered, err := filterCourses(courses, inProgress, filter, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2}, courseIDs(filtered))
+	})
+
+	t.Run("all courses", func(t *testing.T) {
+		filter := CourseFilter{Classification: ClassificationAll}
+
+		filtered, err := filterCourses(courses, map[int]bool{}, filter, now)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3, 5}, courseIDs(filtered))
+	})
+
+	t.Run("bad pattern", func(t *testing.T) {
+		filter := CourseFilter{Exclude: []string{"[S23"}}
+
+		_, err := filterCourses(courses, inProgress, filter, now)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestGetTrackedCourses(t *testing.T) {
+	gradesTable, err := os.ReadFile("testdata/gradereport_user_get_grades_table.json")
+	assert.NoError(t, err)
+
+	var inProgress atomic.Value
+	inProgress.Store(`{"courses": [{"id": 1}, {"id": 2}]}`)
+	tableRequests := map[string]int{}
+	mu := sync.Mutex{}
+
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+
+		switch r.PostForm.Get("wsfunction") {
+		case "core_webservice_get_site_info":
+			w.Write([]byte(`{"userid": 7}`))
+		case "core_enrol_get_users_courses":
+			w.Write([]byte(`[{"id": 1, "fullname": "AGLA"}, {"id": 2, "fullname": "Physics"}]`))
+		case "core_course_get_enrolled_courses_by_timeline_classification":
+			w.Write([]byte(inProgress.Load().(string)))
+		case "gradereport_user_get_grades_table":
+			mu.Lock()
+			tableRequests[r.PostForm.Get("courseid")]++
+			mu.Unlock()
+			w.Write(gradesTable)
+		case "gradereport_overview_get_course_grades":
+			w.Write([]byte(`{"grades": [{"courseid": 2, "grade": "70.00", "rawgrade": "70"}]}`))
+		}
+	})
+	defer closeServer()
+
+	moodleAPI, err := NewMoodle(context.Background(), client, FetchConfig{Workers: 1}, logging.Discard())
+	assert.NoError(t, err)
+	filter := CourseFilter{Classification: ClassificationInProgress}
+
+	previous, err := moodleAPI.GetTrackedCourses(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.Len(t, previous, 2)
+
+	t.Run("course leaving the tracked set keeps its snapshot", func(t *testing.T) {
+		inProgress.Store(`{"courses": [{"id": 1}]}`)
+
+		courses, err := moodleAPI.WithSnapshot(previous).GetTrackedCourses(context.Background(), filter)
+
+		assert.NoError(t, err)
+		assert.Len(t, courses, 2)
+		assert.Equal(t, 1, courses[0].ID)
+		assert.False(t, courses[0].Untracked)
+		assert.Equal(t, 2, courses[1].ID)
+		assert.True(t, courses[1].Untracked)
+		assert.Equal(t, previous[1].Grades, courses[1].Grades)
+		assert.Equal(t, previous[1].Total, courses[1].Total)
+		assert.Equal(t, map[string]int{"1": 2, "2": 1}, tableRequests)
+	})
+
+	t.Run("untracked course without snapshot is left out", func(t *testing.T) {
+		courses, err := moodleAPI.GetTrackedCourses(context.Background(), filter)
+
+		assert.NoError(t, err)
+		assert.Len(t, courses, 1)
+		assert.Equal(t, 1, courses[0].ID)
+	})
+}
@@ -0,0 +1,104 @@
+package moodle
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+// CourseFingerprint summarizes what moodle reports about course grades
+// without the grades table. Grades of a course whose fingerprint has not
+// changed since the previous check are taken from the previous snapshot.
+type CourseFingerprint struct {
+	Timemodified int64
+	GradeItems   int
+	Grades       int
+	LastGraded   int64
+}
+
+// WithSnapshot returns a copy of the API that reuses grades from the
+// previous snapshot when the course fingerprint allows it.
+func (moodle Moodle) WithSnapshot(previous []Course) Moodle {
+	moodle.previous = make(map[int]Course, len(previous))
+	for _, course := range previous {
+		moodle.previous[course.ID] = course
+	}
+
+	return moodle
+}
+
+func (moodle Moodle) GetCourseFingerprint(ctx context.Context, course Course) (CourseFingerprint, error) {
+	data := map[string]string{
+		"courseid":   fmt.Sprint(course.ID),
+		"userids[0]": moodle.userid,
+	}
+
+	gradesJSON, err := moodle.MoodleAPIRequest(ctx, "core_grades_get_grades", data)
+	if err != nil {
+		return CourseFingerprint{}, fmt.Errorf("failed to get course fingerprint: %w", err)
+	}
+
+	items := gjson.GetBytes(gradesJSON, "items")
+	if !items.IsArray() {
+		return CourseFingerprint{}, fmt.Errorf("failed to get course fingerprint: no grade items")
+	}
+
+	fingerprint := CourseFingerprint{
+		Timemodified: course.Timemodified,
+		GradeItems:   len(items.Array()),
+	}
+
+	for _, item := range items.Array() {
+		for _, grade := range item.Get("grades").Array() {
+			if grade.Get("grade").String() != "" {
+				fingerprint.Grades++
+			}
+
+			for _, date := range []string{"dategraded", "datesubmitted"} {
+				timestamp := grade.Get(date).Int()
+				if timestamp > fingerprint.LastGraded {
+					fingerprint.LastGraded = timestamp
+				}
+			}
+		}
+	}
+
+	return fingerprint, nil
+}
+
+// reusePreviousGrades fills the course from the previous snapshot if its
+// fingerprint is unchanged and a full refresh is not due yet. The fingerprint
+// is set on every course, so the next check can compare it.
+func (moodle Moodle) reusePreviousGrades(ctx context.Context, course *Course) bool {
+	if moodle.fetchCfg.FullRefreshEvery <= 0 {
+		return false
+	}
+
+	fingerprint, err := moodle.GetCourseFingerprint(ctx, *course)
+	if err != nil {
+		moodle.log.WarnContext(
+			ctx,
+			"failed to check course for changes",
+			logging.KeyCourseID, course.ID,
+			logging.KeyCourse, course.Fullname,
+			logging.Err(err),
+		)
+		return false
+	}
+	course.Fingerprint = fingerprint
+
+	previousCourse, ok := moodle.previous[course.ID]
+	isFullRefreshDue := previousCourse.ReusedChecks+1 >= moodle.fetchCfg.FullRefreshEvery
+	if !ok || fingerprint != previousCourse.Fingerprint || isFullRefreshDue {
+		return false
+	}
+
+	course.Grades = previousCourse.Grades
+	course.Categories = previousCourse.Categories
+	course.ReusedChecks = previousCourse.ReusedChecks + 1
+
+	return true
+}
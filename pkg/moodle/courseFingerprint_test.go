@@ -0,0 +1,102 @@
+package moodle
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+func TestIncrementalPolling(t *testing.T) {
+	gradesTable, err := os.ReadFile("testdata/gradereport_user_get_grades_table.json")
+	assert.NoError(t, err)
+
+	var tableRequests int32
+	lastGraded := int64(1684000000)
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+
+		switch r.PostForm.Get("wsfunction") {
+		case "core_webservice_get_site_info":
+			w.Write([]byte(`{"userid": 7}`))
+		case "core_enrol_get_users_courses":
+			w.Write([]byte(`[{"id": 42, "fullname": "AGLA", "timemodified": 1680000000}]`))
+		case "core_grades_get_grades":
+			fmt.Fprintf(w, `{"items": [{"name": "Lab 4", "grades": [{"userid": 7, "grade": "8.00", "dategraded": %d}]}]}`, atomic.LoadInt64(&lastGraded))
+		case "gradereport_user_get_grades_table":
+			atomic.AddInt32(&tableRequests, 1)
+			w.Write(gradesTable)
+		case "gradereport_overview_get_course_grades":
+			w.Write([]byte(`{"grades": []}`))
+		}
+	})
+	defer closeServer()
+
+	fetchCfg := FetchConfig{Workers: 1, FullRefreshEvery: 3}
+	moodleAPI, err := NewMoodle(context.Background(), client, fetchCfg, logging.Discard())
+	assert.NoError(t, err)
+
+	check := func(previous []Course) []Course {
+		courses, err := moodleAPI.WithSnapshot(previous).GetTrackedCourses(context.Background(), allCourses)
+		assert.NoError(t, err)
+		assert.Len(t, courses[0].Grades, 4)
+		return courses
+	}
+
+	courses := check(nil)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tableRequests))
+	assert.NotEqual(t, CourseFingerprint{}, courses[0].Fingerprint, "first check keeps the fingerprint for the next one")
+
+	courses = check(courses)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tableRequests), "unchanged course is reused")
+	assert.Equal(t, 1, courses[0].ReusedChecks)
+
+	courses = check(courses)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tableRequests))
+	assert.Equal(t, 2, courses[0].ReusedChecks)
+
+	courses = check(courses)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&tableRequests), "full refresh is due")
+	assert.Equal(t, 0, courses[0].ReusedChecks)
+
+	atomic.StoreInt64(&lastGraded, 1685000000)
+	check(courses)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&tableRequests), "new grade changes the fingerprint")
+}
+
+func TestFingerprintWithoutIncrementalPolling(t *testing.T) {
+	var fingerprintRequests int32
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+
+		switch r.PostForm.Get("wsfunction") {
+		case "core_webservice_get_site_info":
+			w.Write([]byte(`{"userid": 7}`))
+		case "core_enrol_get_users_courses":
+			w.Write([]byte(`[{"id": 42, "fullname": "AGLA"}]`))
+		case "core_grades_get_grades":
+			atomic.AddInt32(&fingerprintRequests, 1)
+			w.Write([]byte(`{"items": []}`))
+		case "gradereport_user_get_grades_table":
+			w.Write([]byte(`{"tables": [{"courseid": 42, "tabledata": []}]}`))
+		case "gradereport_overview_get_course_grades":
+			w.Write([]byte(`{"grades": []}`))
+		}
+	})
+	defer closeServer()
+
+	moodleAPI, err := NewMoodle(context.Background(), client, FetchConfig{Workers: 1}, logging.Discard())
+	assert.NoError(t, err)
+
+	courses, err := moodleAPI.GetTrackedCourses(context.Background(), allCourses)
+	assert.NoError(t, err)
+
+	assert.Equal(t, CourseFingerprint{}, courses[0].Fingerprint)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fingerprintRequests))
+}
@@ -0,0 +1,156 @@
+package moodle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+// FetchConfig configures fetching of course grades. With FullRefreshEvery
+// set, unchanged courses are fetched in full only every FullRefreshEvery
+// checks, zero disables the incremental polling.
+type FetchConfig struct {
+	Workers          int
+	CourseTimeout    time.Duration
+	FullRefreshEvery int
+}
+
+func DefaultFetchConfig() FetchConfig {
+	return FetchConfig{
+		Workers:       4,
+		CourseTimeout: 30 * time.Second,
+	}
+}
+
+func (cfg FetchConfig) withDefaults() FetchConfig {
+	defaultCfg := DefaultFetchConfig()
+
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultCfg.Workers
+	}
+	if cfg.CourseTimeout <= 0 {
+		cfg.CourseTimeout = defaultCfg.CourseTimeout
+	}
+
+	return cfg
+}
+
+// fetchCoursesGrades fills grades of the courses using a pool of workers.
+// Courses that failed are marked as GradesUnavailable, an error joining the
+// errors of the courses is returned only if no course could be fetched.
+func (moodle Moodle) fetchCoursesGrades(ctx context.Context, courses []Course) error {
+	courseInxs := make(chan int)
+	courseErrs := make([]error, len(courses))
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < moodle.fetchCfg.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range courseInxs {
+				courseErrs[i] = moodle.fetchCourseGrades(ctx, &courses[i])
+			}
+		}()
+	}
+
+	for i := range courses {
+		courseInxs <- i
+	}
+	close(courseInxs)
+
+	wg.Wait()
+
+	failedCourses := 0
+	for _, course := range courses {
+		if course.GradesUnavailable {
+			failedCourses++
+		}
+	}
+
+	areAllCoursesFailed := len(courses) > 0 && failedCourses == len(courses)
+	if areAllCoursesFailed {
+		return fmt.Errorf("failed to fetch grades of all %d courses: %w", len(courses), errors.Join(courseErrs...))
+	}
+
+	moodle.fillCourseTotals(ctx, courses)
+
+	return nil
+}
+
+// fetchCourseGrades returns the error the course is marked as
+// GradesUnavailable with.
+func (moodle Moodle) fetchCourseGrades(ctx context.Context, course *Course) error {
+	courseCtx, cancel := context.WithTimeout(ctx, moodle.fetchCfg.CourseTimeout)
+	defer cancel()
+
+	if moodle.reusePreviousGrades(courseCtx, course) {
+		moodle.log.DebugContext(ctx, "reused course grades", logging.KeyCourseID, course.ID, logging.KeyCourse, course.Fullname)
+		return nil
+	}
+
+	start := time.Now()
+	courseGrades, err := moodle.GetCourseGrades(courseCtx, *course)
+
+	var tableWarning ParseWarning
+	if errors.As(err, &tableWarning) {
+		tableWarning.CourseID = course.ID
+		tableWarning.CourseName = course.Fullname
+		course.Warnings = []ParseWarning{tableWarning}
+		course.Fingerprint = CourseFingerprint{}
+		course.GradesUnavailable = true
+		return tableWarning
+	}
+	if err != nil {
+		moodle.log.WarnContext(
+			ctx,
+			"failed to get course grades",
+			logging.KeyCourseID, course.ID,
+			logging.KeyCourse, course.Fullname,
+			logging.Err(err),
+		)
+		course.Fingerprint = CourseFingerprint{}
+		course.GradesUnavailable = true
+		return err
+	}
+
+	for _, warning := range courseGrades.Warnings {
+		warning.CourseID = course.ID
+		warning.CourseName = course.Fullname
+		course.Warnings = append(course.Warnings, warning)
+	}
+
+	course.Grades = courseGrades.Grades
+	course.Categories = courseGrades.Categories
+
+	moodle.log.DebugContext(
+		ctx,
+		"fetched course grades",
+		logging.KeyCourseID, course.ID,
+		logging.KeyCourse, course.Fullname,
+		logging.KeyDuration, time.Since(start),
+	)
+
+	return nil
+}
+
+// fillCourseTotals sets the totals of the courses, they are marked as
+// TotalUnavailable if the overview could not be fetched.
+func (moodle Moodle) fillCourseTotals(ctx context.Context, courses []Course) {
+	courseTotals, err := moodle.GetCourseTotals(ctx)
+	if err != nil {
+		moodle.log.WarnContext(ctx, "failed to get course totals", logging.Err(err))
+		for i := range courses {
+			courses[i].TotalUnavailable = true
+		}
+		return
+	}
+
+	for i := range courses {
+		courses[i].Total = courseTotals[courses[i].ID]
+	}
+}
@@ -0,0 +1,249 @@
+package moodle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+var allCourses = CourseFilter{Classification: ClassificationAll}
+
+// newFakeMoodle serves courses 1 to courseCount, grade tables are served by
+// table.
+func newFakeMoodle(t *testing.T, courseCount int, table func(w http.ResponseWriter, r *http.Request)) (*Client, func()) {
+	return newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+
+		switch r.PostForm.Get("wsfunction") {
+		case "core_webservice_get_site_info":
+			w.Write([]byte(`{"userid": 7}`))
+		case "core_enrol_get_users_courses":
+			courses := "["
+			for id := 1; id <= courseCount; id++ {
+				if id > 1 {
+					courses += ","
+				}
+				courses += fmt.Sprintf(`{"id": %d, "fullname": "Course %d"}`, id, id)
+			}
+			w.Write([]byte(courses + "]"))
+		case "gradereport_user_get_grades_table":
+			table(w, r)
+		case "gradereport_overview_get_course_grades":
+			w.Write([]byte(`{"grades": []}`))
+		}
+	})
+}
+
+func TestFetchCoursesGrades(t *testing.T) {
+	gradesTable, err := os.ReadFile("testdata/gradereport_user_get_grades_table.json")
+	assert.NoError(t, err)
+
+	t.Run("workers bound concurrent requests", func(t *testing.T) {
+		mu := sync.Mutex{}
+		inFlight, maxInFlight := 0, 0
+		client, closeServer := newFakeMoodle(t, 6, func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+
+			time.Sleep(50 * time.Millisecond)
+
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+
+			w.Write(gradesTable)
+		})
+		defer closeServer()
+
+		moodleAPI, err := NewMoodle(context.Background(), client, FetchConfig{Workers: 2}, logging.Discard())
+		assert.NoError(t, err)
+
+		courses, err := moodleAPI.GetTrackedCourses(context.Background(), allCourses)
+
+		assert.NoError(t, err)
+		assert.Len(t, courses, 6)
+		for _, course := range courses {
+			assert.False(t, course.GradesUnavailable)
+		}
+		assert.Equal(t, 2, maxInFlight)
+	})
+
+	t.Run("slow course times out alone", func(t *testing.T) {
+		client, closeServer := newFakeMoodle(t, 3, func(w http.ResponseWriter, r *http.Request) {
+			if r.PostForm.Get("courseid") == "2" {
+				<-r.Context().Done()
+				return
+			}
+
+			w.Write(gradesTable)
+		})
+		defer closeServer()
+
+		fetchCfg := FetchConfig{Workers: 3, CourseTimeout: 100 * time.Millisecond}
+		moodleAPI, err := NewMoodle(context.Background(), client, fetchCfg, logging.Discard())
+		assert.NoError(t, err)
+
+		start := time.Now()
+		courses, err := moodleAPI.GetTrackedCourses(context.Background(), allCourses)
+
+		assert.NoError(t, err)
+		assert.True(t, time.Since(start) < 2*time.Second)
+		assert.False(t, courses[0].GradesUnavailable)
+		assert.True(t, courses[1].GradesUnavailable)
+		assert.Empty(t, courses[1].Grades)
+		assert.False(t, courses[2].GradesUnavailable)
+		assert.Len(t, courses[2].Grades, 4)
+	})
+
+	t.Run("failed courses are partial results", func(t *testing.T) {
+		client, closeServer := newFakeMoodle(t, 3, func(w http.ResponseWriter, r *http.Request) {
+			if r.PostForm.Get("courseid") == "3" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.Write(gradesTable)
+		})
+		defer closeServer()
+
+		moodleAPI, err := NewMoodle(context.Background(), client, FetchConfig{Workers: 2}, logging.Discard())
+		assert.NoError(t, err)
+
+		courses, err := moodleAPI.GetTrackedCourses(context.Background(), allCourses)
+
+		assert.NoError(t, err)
+		assert.False(t, courses[0].GradesUnavailable)
+		assert.False(t, courses[1].GradesUnavailable)
+		assert.True(t, courses[2].GradesUnavailable)
+	})
+
+	t.Run("all courses failed", func(t *testing.T) {
+		client, closeServer := newFakeMoodle(t, 2, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+		defer closeServer()
+
+		moodleAPI, err := NewMoodle(context.Background(), client, FetchConfig{Workers: 2}, logging.Discard())
+		assert.NoError(t, err)
+
+		_, err = moodleAPI.GetTrackedCourses(context.Background(), allCourses)
+
+		var statusErr StatusError
+		assert.True(t, errors.As(err, &statusErr), "errors of the courses are kept")
+		assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	})
+
+	t.Run("all grade tables malformed", func(t *testing.T) {
+		client, closeServer := newFakeMoodle(t, 2, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"tables": "broken"}`))
+		})
+		defer closeServer()
+
+		moodleAPI, err := NewMoodle(context.Background(), client, FetchConfig{Workers: 2}, logging.Discard())
+		assert.NoError(t, err)
+
+		_, err = moodleAPI.GetTrackedCourses(context.Background(), allCourses)
+
+		var parseWarning ParseWarning
+		assert.True(t, errors.As(err, &parseWarning))
+	})
+}
+
+func TestCourseGradesUnavailable(t *testing.T) {
+	gradesTable, err := os.ReadFile("testdata/gradereport_user_get_grades_table.json")
+	assert.NoError(t, err)
+
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+
+		switch r.PostForm.Get("wsfunction") {
+		case "core_webservice_get_site_info":
+			w.Write([]byte(`{"userid": 7}`))
+		case "core_enrol_get_users_courses":
+			w.Write([]byte(`[{"id": 42, "fullname": "AGLA"}, {"id": 43, "fullname": "Broken"}]`))
+		case "gradereport_user_get_grades_table":
+			if r.PostForm.Get("courseid") == "43" {
+				w.Write([]byte(`{"exception": "moodle_exception", "errorcode": "nopermissions", "message": "No permissions"}`))
+				return
+			}
+			w.Write(gradesTable)
+		case "gradereport_overview_get_course_grades":
+			w.Write([]byte(`{"grades": [{"courseid": 42, "grade": "3.20", "rawgrade": "3.2"}]}`))
+		}
+	})
+	defer closeServer()
+
+	moodleAPI, err := NewMoodle(context.Background(), client, FetchConfig{Workers: 2}, logging.Discard())
+	assert.NoError(t, err)
+
+	courses, err := moodleAPI.GetTrackedCourses(context.Background(), allCourses)
+	assert.NoError(t, err)
+
+	assert.Len(t, courses, 2)
+	assert.Equal(t, 42, courses[0].ID)
+	assert.False(t, courses[0].GradesUnavailable)
+	assert.Len(t, courses[0].Grades, 4)
+	assert.Equal(t, CourseTotal{Grade: "3.20", RawGrade: "3.2"}, courses[0].Total)
+	assert.Equal(t, 43, courses[1].ID)
+	assert.True(t, courses[1].GradesUnavailable)
+	assert.False(t, courses[0].TotalUnavailable)
+}
+
+func TestCourseTotalsUnavailable(t *testing.T) {
+	client, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+
+		switch r.PostForm.Get("wsfunction") {
+		case "core_webservice_get_site_info":
+			w.Write([]byte(`{"userid": 7}`))
+		case "core_enrol_get_users_courses":
+			w.Write([]byte(`[{"id": 42, "fullname": "AGLA"}]`))
+		case "gradereport_user_get_grades_table":
+			w.Write([]byte(`{"tables": [{"courseid": 42, "tabledata": []}]}`))
+		case "gradereport_overview_get_course_grades":
+			w.Write([]byte(`{"exception": "moodle_exception", "errorcode": "nopermissions", "message": "No permissions"}`))
+		}
+	})
+	defer closeServer()
+
+	moodleAPI, err := NewMoodle(context.Background(), client, FetchConfig{Workers: 1}, logging.Discard())
+	assert.NoError(t, err)
+
+	courses, err := moodleAPI.GetTrackedCourses(context.Background(), allCourses)
+	assert.NoError(t, err)
+
+	assert.Len(t, courses, 1)
+	assert.False(t, courses[0].GradesUnavailable)
+	assert.True(t, courses[0].TotalUnavailable)
+}
+
+func TestRateLimit(t *testing.T) {
+	fastClient, closeServer := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	defer closeServer()
+
+	client := NewClient(ClientConfig{BaseURL: fastClient.cfg.BaseURL, RequestsPerSecond: 20}, logging.Discard())
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := client.Call(context.Background(), "core_webservice_get_site_info", nil)
+		assert.NoError(t, err)
+	}
+
+	// the first call is free, the other four wait 50ms each
+	assert.True(t, time.Since(start) >= 190*time.Millisecond)
+}
@@ -2,13 +2,24 @@ package moodle
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"os"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/secret"
 )
 
+// Credentials are read from the credentials file, non empty Overrides take
+// precedence over it, e.g. the ones set by the environment. The token of
+// Overrides is only used while the file has none, a refreshed token is saved
+// to the file and must win over the expired override. The file is encrypted
+// with Key if it is set. Warnings about the file go to Log.
 type Credentials struct {
 	CredentialsPath string
+	Key             secret.Key
+	Overrides       CredentialsData
+	Log             *slog.Logger
 }
 
 type CredentialsData struct {
@@ -17,19 +28,83 @@ type CredentialsData struct {
 	Token    string `json:"token"`
 }
 
-func newCredentials(credentialsPath string) Credentials {
-	return Credentials{credentialsPath}
+func NewCredentials(credentialsPath string, key secret.Key, overrides CredentialsData, log *slog.Logger) Credentials {
+	return Credentials{credentialsPath, key, overrides, log}
 }
 
 func (cm Credentials) get() (CredentialsData, error) {
-	credentialsFile, err := os.OpenFile(cm.CredentialsPath, os.O_RDONLY|os.O_CREATE, 0644)
+	credentials, err := cm.read()
 	if err != nil {
-		return CredentialsData{}, fmt.Errorf("failed to get credentials")
+		return CredentialsData{}, err
 	}
 
-	credentialsJSON, err := io.ReadAll(credentialsFile)
+	if cm.Overrides.Login != "" {
+		credentials.Login = cm.Overrides.Login
+	}
+	if cm.Overrides.Password != "" {
+		credentials.Password = cm.Overrides.Password
+	}
+	if credentials.Token == "" {
+		credentials.Token = cm.Overrides.Token
+	}
+
+	if credentials.Login == "" && credentials.Password == "" && credentials.Token == "" {
+		return CredentialsData{}, fmt.Errorf("innopolis credentials are empty: %w", ErrWrongCredentials)
+	}
+
+	return credentials, nil
+}
+
+// Validate checks that there is a token or a login and a password to get
+// one.
+func (cm Credentials) Validate() error {
+	credentials, err := cm.get()
 	if err != nil {
-		return CredentialsData{}, fmt.Errorf("failed to get credentials")
+		return err
+	}
+
+	hasLogin := credentials.Login != "" && credentials.Password != ""
+	if !hasLogin && credentials.Token == "" {
+		return fmt.Errorf("innopolis credentials need a token or both a login and a password")
+	}
+
+	return nil
+}
+
+// Secrets returns the password and the token to redact them, none if the
+// credentials cannot be read.
+func (cm Credentials) Secrets() []string {
+	credentials, err := cm.get()
+	if err != nil {
+		return nil
+	}
+
+	return []string{credentials.Password, credentials.Token, cm.Overrides.Token}
+}
+
+// Login returns the login to tell the users apart in the logs, none if the
+// credentials cannot be read or have only a token.
+func (cm Credentials) Login() string {
+	credentials, err := cm.get()
+	if err != nil {
+		return ""
+	}
+
+	return credentials.Login
+}
+
+// read returns the credentials of the file only, a missing file has none.
+func (cm Credentials) read() (CredentialsData, error) {
+	if cm.CredentialsPath == "" {
+		return CredentialsData{}, nil
+	}
+
+	credentialsJSON, err := secret.ReadFile(cm.CredentialsPath, cm.Key, cm.Log)
+	if errors.Is(err, os.ErrNotExist) {
+		return CredentialsData{}, nil
+	}
+	if err != nil {
+		return CredentialsData{}, fmt.Errorf("failed to get credentials: %w", err)
 	}
 
 	var credentials CredentialsData
@@ -38,39 +113,62 @@ func (cm Credentials) get() (CredentialsData, error) {
 		return CredentialsData{}, fmt.Errorf("failed to get credentials")
 	}
 
-	if credentials.Login == "" && credentials.Password == "" && credentials.Token == "" {
-		return CredentialsData{}, fmt.Errorf("innopolis credentials are empty")
-	}
-
 	return credentials, nil
 }
 
+// save keeps the new token in the credentials file, the overrides are not
+// written to it.
 func (cm Credentials) save(newToken string) error {
-	newCredentials, err := cm.get()
+	if cm.CredentialsPath == "" {
+		return nil
+	}
+
+	newCredentials, err := cm.read()
 	if err != nil {
 		return fmt.Errorf("failed to save credentials")
 	}
 
 	newCredentials.Token = newToken
 
-	credentialsFile, err := os.OpenFile(
-		cm.CredentialsPath,
-		os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
-		0644,
-	)
+	return cm.write(newCredentials)
+}
+
+// Set replaces the login and the password in the credentials file, the
+// token of the old ones is dropped.
+func (cm Credentials) Set(login, password string) error {
+	if cm.CredentialsPath == "" {
+		return fmt.Errorf("failed to save credentials: the credentials path is not set")
+	}
+
+	return cm.write(CredentialsData{Login: login, Password: password})
+}
+
+// Rewrite writes the credentials file again, e.g. to encrypt it once a key
+// is set.
+func (cm Credentials) Rewrite() error {
+	_, err := os.Stat(cm.CredentialsPath)
 	if err != nil {
-		return fmt.Errorf("failed to save credentials")
+		return fmt.Errorf("failed to get credentials: %v", err)
 	}
 
-	credentialsJSON, err := json.Marshal(newCredentials)
+	credentials, err := cm.read()
 	if err != nil {
-		return fmt.Errorf("failed to save credentials")
+		return err
 	}
 
-	_, err = credentialsFile.Write(credentialsJSON)
+	return cm.write(credentials)
+}
+
+func (cm Credentials) write(credentials CredentialsData) error {
+	credentialsJSON, err := json.Marshal(credentials)
 	if err != nil {
 		return fmt.Errorf("failed to save credentials")
 	}
 
+	err = secret.WriteFile(cm.CredentialsPath, credentialsJSON, cm.Key)
+	if err != nil {
+		return fmt.Errorf("failed to save credentials: %v", err)
+	}
+
 	return nil
 }
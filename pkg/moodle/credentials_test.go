@@ -0,0 +1,37 @@
+package moodle
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/secret"
+)
+
+func TestCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moodle-credentials.json")
+	overrides := CredentialsData{Login: "env-login", Token: "env-token"}
+	credentials := NewCredentials(path, secret.Key{}, overrides, logging.Discard())
+
+	assert.NoError(t, credentials.Set("file-login", "file-password"))
+
+	t.Run("override token is used while the file has none", func(t *testing.T) {
+		data, err := credentials.get()
+
+		assert.NoError(t, err)
+		assert.Equal(t, CredentialsData{Login: "env-login", Password: "file-password", Token: "env-token"}, data)
+	})
+
+	t.Run("refreshed token wins over the override", func(t *testing.T) {
+		assert.NoError(t, credentials.save("refreshed-token"))
+
+		data, err := credentials.get()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "refreshed-token", data.Token)
+		assert.Equal(t, "env-login", data.Login)
+		assert.Contains(t, credentials.Secrets(), "env-token")
+	})
+}
@@ -0,0 +1,123 @@
+package moodle
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type GradeValueKind string
+
+const (
+	GradeValueAbsent     GradeValueKind = "absent"
+	GradeValueNumeric    GradeValueKind = "numeric"
+	GradeValuePercentage GradeValueKind = "percentage"
+	GradeValueLetter     GradeValueKind = "letter"
+	GradeValueScale      GradeValueKind = "scale"
+	GradeValueRange      GradeValueKind = "range"
+)
+
+// GradeValue is a typed view of a grade table cell. Raw always keeps the
+// text as it was shown by Moodle, Number is set for numeric and percentage
+// values, Min and Max are set for ranges.
+type GradeValue struct {
+	Raw    string
+	Kind   GradeValueKind
+	Number float64
+	Min    float64
+	Max    float64
+}
+
+type GradeValues struct {
+	Grade        GradeValue
+	Persentage   GradeValue
+	Range        GradeValue
+	Weight       GradeValue
+	Contribution GradeValue
+}
+
+var (
+	letterGradeRegex = regexp.MustCompile(`^[A-F][+-]?$`)
+	rangeRegex       = regexp.MustCompile(`^(-?[\d.,]+)\s*[-–—]\s*(-?[\d.,]+)$`)
+)
+
+func (g GradeReport) Values() GradeValues {
+	return GradeValues{
+		Grade:        ParseGradeValue(g.Grade),
+		Persentage:   ParseGradeValue(g.Persentage),
+		Range:        ParseGradeRange(g.Range),
+		Weight:       ParseGradeValue(g.Weight),
+		Contribution: ParseGradeValue(g.Contribution),
+	}
+}
+
+func ParseGradeValue(raw string) GradeValue {
+	value := strings.TrimSpace(strings.ReplaceAll(raw, "\u00a0", " "))
+
+	if isAbsentValue(value) {
+		return GradeValue{Raw: raw, Kind: GradeValueAbsent}
+	}
+
+	if strings.HasSuffix(value, "%") {
+		number, ok := parseNumber(strings.TrimSuffix(value, "%"))
+		if ok {
+			return GradeValue{Raw: raw, Kind: GradeValuePercentage, Number: number}
+		}
+	}
+
+	number, ok := parseNumber(value)
+	if ok {
+		return GradeValue{Raw: raw, Kind: GradeValueNumeric, Number: number}
+	}
+
+	if letterGradeRegex.MatchString(value) {
+		return GradeValue{Raw: raw, Kind: GradeValueLetter}
+	}
+
+	return GradeValue{Raw: raw, Kind: GradeValueScale}
+}
+
+func ParseGradeRange(raw string) GradeValue {
+	value := strings.TrimSpace(strings.ReplaceAll(raw, "&ndash;", "-"))
+
+	if isAbsentValue(value) {
+		return GradeValue{Raw: raw, Kind: GradeValueAbsent}
+	}
+
+	matches := rangeRegex.FindStringSubmatch(value)
+	if len(matches) < 3 {
+		return ParseGradeValue(raw)
+	}
+
+	min, minOk := parseNumber(matches[1])
+	max, maxOk := parseNumber(matches[2])
+	if !minOk || !maxOk {
+		return ParseGradeValue(raw)
+	}
+
+	return GradeValue{Raw: raw, Kind: GradeValueRange, Min: min, Max: max}
+}
+
+func (v GradeValue) IsNumeric() bool {
+	return v.Kind == GradeValueNumeric
+}
+
+func (v GradeValue) IsPercentage() bool {
+	return v.Kind == GradeValuePercentage
+}
+
+func isAbsentValue(value string) bool {
+	return value == "" || value == "-" || value == "–" || value == "Error"
+}
+
+func parseNumber(str string) (float64, bool) {
+	str = strings.TrimSpace(str)
+	str = strings.ReplaceAll(str, ",", ".")
+
+	number, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return number, true
+}
@@ -0,0 +1,60 @@
+package moodle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGradeValue(t *testing.T) {
+	t.Run("numeric grade", func(t *testing.T) {
+		value := ParseGradeValue("8.50")
+
+		assert.Equal(t, GradeValue{Raw: "8.50", Kind: GradeValueNumeric, Number: 8.5}, value)
+	})
+
+	t.Run("percentage with comma", func(t *testing.T) {
+		value := ParseGradeValue("50,00 %")
+
+		assert.Equal(t, GradeValue{Raw: "50,00 %", Kind: GradeValuePercentage, Number: 50}, value)
+	})
+
+	t.Run("letter grade", func(t *testing.T) {
+		value := ParseGradeValue("B+")
+
+		assert.Equal(t, GradeValue{Raw: "B+", Kind: GradeValueLetter}, value)
+	})
+
+	t.Run("scale grade", func(t *testing.T) {
+		value := ParseGradeValue("Satisfactory")
+
+		assert.Equal(t, GradeValue{Raw: "Satisfactory", Kind: GradeValueScale}, value)
+	})
+
+	t.Run("absent grade", func(t *testing.T) {
+		for _, raw := range []string{"", "-", "Error"} {
+			assert.Equal(t, GradeValueAbsent, ParseGradeValue(raw).Kind)
+		}
+	})
+}
+
+func TestParseGradeRange(t *testing.T) {
+	t.Run("en dash range", func(t *testing.T) {
+		value := ParseGradeRange("0–10")
+
+		assert.Equal(t, GradeValue{Raw: "0–10", Kind: GradeValueRange, Min: 0, Max: 10}, value)
+	})
+
+	t.Run("escaped range", func(t *testing.T) {
+		value := ParseGradeRange("0.00&ndash;100.00")
+
+		assert.Equal(t, GradeValueRange, value.Kind)
+		assert.Equal(t, 100.0, value.Max)
+	})
+
+	t.Run("letter range is not numeric", func(t *testing.T) {
+		value := ParseGradeRange("F–A")
+
+		assert.Equal(t, GradeValueScale, value.Kind)
+	})
+}
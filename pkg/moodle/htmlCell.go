@@ -0,0 +1,120 @@
+package moodle
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/net/html"
+)
+
+// htmlCell is the content of a grade table cell with markup stripped. Text
+// keeps line and paragraph breaks, Title is the first title attribute found,
+// Header fields come from the element marked with the "gradeitemheader" class.
+type htmlCell struct {
+	Text        string
+	Title       string
+	Links       []string
+	Classes     []string
+	HeaderTitle string
+	HeaderLink  string
+}
+
+const gradeItemHeaderClass = "gradeitemheader"
+
+var paragraphTags = []string{"p", "div", "li", "ul", "ol", "table", "tr", "h1", "h2", "h3", "h4", "h5", "h6"}
+
+func parseHTMLCell(content string) htmlCell {
+	cell := htmlCell{Links: []string{}, Classes: []string{}}
+	text := strings.Builder{}
+
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.TextToken:
+			text.WriteString(token.Data)
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			cell.addAttributes(token)
+
+			if token.Data == "br" {
+				text.WriteString("\n")
+			}
+			if slices.Contains(paragraphTags, token.Data) {
+				text.WriteString("\n\n")
+			}
+
+		case html.EndTagToken:
+			if slices.Contains(paragraphTags, token.Data) {
+				text.WriteString("\n\n")
+			}
+		}
+	}
+
+	cell.Text = normalizeCellText(text.String())
+
+	return cell
+}
+
+func (cell *htmlCell) addAttributes(token html.Token) {
+	title := ""
+	link := ""
+	classes := []string{}
+	for _, attr := range token.Attr {
+		switch attr.Key {
+		case "title":
+			title = strings.TrimSpace(attr.Val)
+		case "href":
+			if token.Data == "a" && attr.Val != "" && !strings.HasPrefix(attr.Val, "#") {
+				link = attr.Val
+			}
+		case "class":
+			classes = strings.Fields(attr.Val)
+		}
+	}
+
+	cell.Classes = append(cell.Classes, classes...)
+
+	if title != "" && cell.Title == "" {
+		cell.Title = title
+	}
+
+	if link != "" {
+		cell.Links = append(cell.Links, link)
+	}
+
+	isHeader := slices.Contains(classes, gradeItemHeaderClass)
+	if isHeader && cell.HeaderTitle == "" && cell.HeaderLink == "" {
+		cell.HeaderTitle = title
+		cell.HeaderLink = link
+	}
+}
+
+func (cell htmlCell) hasClass(class string) bool {
+	return slices.Contains(cell.Classes, class)
+}
+
+// normalizeCellText collapses whitespace inside lines, non-breaking spaces
+// included, and keeps at most one empty line between paragraphs.
+func normalizeCellText(text string) string {
+	lines := strings.Split(text, "\n")
+	normalizedLines := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+
+		isRepeatedEmptyLine := line == "" &&
+			(len(normalizedLines) == 0 || normalizedLines[len(normalizedLines)-1] == "")
+		if isRepeatedEmptyLine {
+			continue
+		}
+
+		normalizedLines = append(normalizedLines, line)
+	}
+
+	return strings.TrimSpace(strings.Join(normalizedLines, "\n"))
+}
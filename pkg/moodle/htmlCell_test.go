@@ -0,0 +1,42 @@
+package moodle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHTMLCell(t *testing.T) {
+	t.Run("decodes entities", func(t *testing.T) {
+		cell := parseHTMLCell("0&ndash;10 &amp; &quot;bonus&quot;&nbsp;&#8470;1")
+
+		assert.Equal(t, "0–10 & \"bonus\" №1", cell.Text)
+	})
+
+	t.Run("stray angle brackets", func(t *testing.T) {
+		assert.Equal(t, "a > b", parseHTMLCell("a > b").Text)
+		assert.Equal(t, "5 < 6", parseHTMLCell("5 < 6").Text)
+		assert.Equal(t, "text", parseHTMLCell("text <unclosed").Text)
+	})
+
+	t.Run("keeps paragraph breaks", func(t *testing.T) {
+		cell := parseHTMLCell("<p>First   paragraph</p>\n<p>Second<br>line</p>")
+
+		assert.Equal(t, "First paragraph\n\nSecond\nline", cell.Text)
+	})
+
+	t.Run("grade item header", func(t *testing.T) {
+		cell := parseHTMLCell(`<img title="Assignment"/><a title="Link to Lab" class="gradeitemheader" href="https://moodle/lab">Lab</a>`)
+
+		assert.Equal(t, "Assignment", cell.Title)
+		assert.Equal(t, "Link to Lab", cell.HeaderTitle)
+		assert.Equal(t, "https://moodle/lab", cell.HeaderLink)
+		assert.True(t, cell.hasClass(gradeItemHeaderClass))
+	})
+
+	t.Run("skips anchors without target", func(t *testing.T) {
+		cell := parseHTMLCell(`<a href="#top">top</a><a name="x">x</a><a href="https://moodle/file.pdf">file</a>`)
+
+		assert.Equal(t, []string{"https://moodle/file.pdf"}, cell.Links)
+	})
+}
@@ -1,20 +1,18 @@
 package moodle
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"net/url"
-	"strings"
-	"time"
+	"log/slog"
 )
 
 type Moodle struct {
-	token  string
-	userid string
-	log    *log.Logger
+	client   *Client
+	userid   string
+	fetchCfg FetchConfig
+	previous map[int]Course
+	log      *slog.Logger
 }
 
 type Course struct {
@@ -26,11 +24,45 @@ type Course struct {
 	Enddate           int64  `json:"enddate"`
 	Hidden            bool   `json:"hidden"`
 	Grades            []GradeReport
+	Categories        []GradeCategory
+	Total             CourseTotal
+	Fingerprint       CourseFingerprint
+	ReusedChecks      int
+	Warnings          []ParseWarning `json:"-"`
+	GradesUnavailable bool           `json:"-"`
+	TotalUnavailable  bool           `json:"-"`
+	Untracked         bool           `json:"-"`
 }
 
-func NewMoodle(token MoodleToken, log *log.Logger) (Moodle, error) {
-	moodleAPI := Moodle{token: string(token), log: log}
-	userid, err := moodleAPI.getUserID()
+// CourseTotal is the course grade shown in the grades overview. Grade is
+// formatted by moodle, RawGrade is the number it was formatted from.
+type CourseTotal struct {
+	Grade    string
+	RawGrade string
+}
+
+type courseTotalsJSON struct {
+	Grades []struct {
+		CourseID int    `json:"courseid"`
+		Grade    string `json:"grade"`
+		RawGrade string `json:"rawgrade"`
+	} `json:"grades"`
+}
+
+// NewMoodle makes an API for the user the client token belongs to, the
+// client should be made with Client.WithToken. The client logs to log too.
+func NewMoodle(
+	ctx context.Context,
+	client *Client,
+	fetchCfg FetchConfig,
+	log *slog.Logger,
+) (Moodle, error) {
+	moodleAPI := Moodle{
+		client:   client.withLog(log),
+		fetchCfg: fetchCfg.withDefaults(),
+		log:      log,
+	}
+	userid, err := moodleAPI.getUserID(ctx)
 	if err != nil {
 		return Moodle{}, err
 	}
@@ -39,78 +71,107 @@ func NewMoodle(token MoodleToken, log *log.Logger) (Moodle, error) {
 	return moodleAPI, nil
 }
 
-func (moodle Moodle) GetCourseGrades(course Course) ([]GradeReport, error) {
+func (moodle Moodle) GetCourseGrades(ctx context.Context, course Course) (GradeTable, error) {
 	moodleUser := NewMoodleUser(moodle, moodle.userid)
 
-	courseGrades, err := moodleUser.GetCourseGrades(fmt.Sprint(course.ID))
+	courseGrades, err := moodleUser.GetCourseGrades(ctx, fmt.Sprint(course.ID))
 	if err != nil {
-		return nil, err
+		return GradeTable{}, err
 	}
 
 	return courseGrades, nil
 }
 
-func (moodle Moodle) GetNonHiddenCourses() ([]Course, error) {
-	courses, err := moodle.GetCourses()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get course grades %v", err)
+// GradePath returns titles of the categories containing the grade, outermost
+// first. The course category itself is omitted, as is the category of a
+// total row since the total is titled after it.
+func (course Course) GradePath(grade GradeReport) []string {
+	categories := make(map[int]GradeCategory, len(course.Categories))
+	for _, category := range course.Categories {
+		categories[category.ID] = category
+	}
+
+	categoryID := grade.ParentID
+	if grade.Type == GradeRowCategoryTotal {
+		categoryID = categories[categoryID].ParentID
 	}
 
-	nonHiddenCourses := make([]Course, 0, 0)
-	for _, course := range courses {
-		if course.Hidden {
-			continue
+	path := []string{}
+	for {
+		category, ok := categories[categoryID]
+		isCourseCategory := category.ParentID == 0
+		if !ok || isCourseCategory {
+			break
 		}
 
-		nonHiddenCourses = append(nonHiddenCourses, course)
+		path = append([]string{category.Title}, path...)
+		categoryID = category.ParentID
 	}
 
-	return nonHiddenCourses, nil
+	return path
 }
 
-func (moodle Moodle) GetCourses() ([]Course, error) {
+func (moodle Moodle) getEnrolledCourses(ctx context.Context) ([]Course, error) {
 	data := map[string]string{
 		"userid": moodle.userid,
 	}
 
-	coursesJSON, err := moodle.MoodleAPIRequest("core_enrol_get_users_courses", data)
+	coursesJSON, err := moodle.MoodleAPIRequest(ctx, "core_enrol_get_users_courses", data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get courses: %v", err)
+		return nil, fmt.Errorf("failed to get courses: %w", err)
 	}
 
 	courses, err := moodle.parseCoursesJSON(coursesJSON)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get courses: %v", err)
+		return nil, fmt.Errorf("failed to get courses: %w", err)
 	}
 
-	for i := range courses {
-		courseGrades, err := moodle.GetCourseGrades(courses[i])
-		if err != nil {
-			return nil, fmt.Errorf("failed to get course grades for %q: %v", courses[i].Fullname, err)
-		}
+	return courses, nil
+}
 
-		courses[i].Grades = courseGrades
+func (moodle Moodle) GetCourseTotals(ctx context.Context) (map[int]CourseTotal, error) {
+	data := map[string]string{
+		"userid": moodle.userid,
 	}
 
-	return courses, nil
+	totalsJSON, err := moodle.MoodleAPIRequest(ctx, "gradereport_overview_get_course_grades", data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get course totals: %w", err)
+	}
+
+	var totals courseTotalsJSON
+	err = json.Unmarshal(totalsJSON, &totals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get course totals: %w", err)
+	}
+
+	courseTotals := make(map[int]CourseTotal, len(totals.Grades))
+	for _, total := range totals.Grades {
+		courseTotals[total.CourseID] = CourseTotal{
+			Grade:    total.Grade,
+			RawGrade: total.RawGrade,
+		}
+	}
+
+	return courseTotals, nil
 }
 
 func (api Moodle) parseCoursesJSON(coursesJSON []byte) ([]Course, error) {
 	var courses []Course
 	err := json.Unmarshal(coursesJSON, &courses)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse courses: %v", err)
+		return nil, fmt.Errorf("failed to parse courses: %w", err)
 	}
 
 	return courses, nil
 }
 
-func (api Moodle) getUserID() (string, error) {
+func (api Moodle) getUserID(ctx context.Context) (string, error) {
 	if api.userid != "" {
 		return api.userid, nil
 	}
 
-	info, err := api.getCoreWebsiteInfo()
+	info, err := api.getCoreWebsiteInfo(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -125,8 +186,8 @@ func (api Moodle) getUserID() (string, error) {
 	return userid, nil
 }
 
-func (api Moodle) getCoreWebsiteInfo() ([]byte, error) {
-	info, err := api.MoodleAPIRequest("core_webservice_get_site_info", nil)
+func (api Moodle) getCoreWebsiteInfo(ctx context.Context) ([]byte, error) {
+	info, err := api.MoodleAPIRequest(ctx, "core_webservice_get_site_info", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -134,48 +195,14 @@ func (api Moodle) getCoreWebsiteInfo() ([]byte, error) {
 }
 
 func (api Moodle) MoodleAPIRequest(
+	ctx context.Context,
 	requestFunction string,
 	dataArgs map[string]string,
 ) ([]byte, error) {
-	moodleURL := "https://moodle.innopolis.university/webservice/rest/server.php?moodlewsrestformat=json&wsfunction=" + requestFunction
-	data := url.Values{
-		"moodlewssettingfilter":  {"True"},
-		"moodlewssettingfileurl": {"False"},
-		"wsfunction":             {requestFunction},
-		"wstoken":                {api.token},
-	}
-	for k, v := range dataArgs {
-		data.Add(k, v)
-	}
-
-	client := http.Client{
-		Timeout: time.Second * 10,
-	}
-	moodleReq, err := http.NewRequest(http.MethodPost, moodleURL, strings.NewReader(data.Encode()))
-	moodleReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	if err != nil {
-		return nil, fmt.Errorf("failed to make a request to moodle: %v", err)
-	}
-
-	response, err := client.Do(moodleReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make a request to moodle: %v", err)
-	}
-	defer response.Body.Close()
-
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make a request to moodle: %v", err)
-	}
-
-	if strings.Contains(string(body), "Invalid token") {
-		return nil, fmt.Errorf("invalid token")
-	}
-
-	return body, nil
+	return api.client.Call(ctx, requestFunction, dataArgs)
 }
 
-func (api Moodle) isTokenGood() bool {
-	_, err := api.getCoreWebsiteInfo()
+func (api Moodle) isTokenGood(ctx context.Context) bool {
+	_, err := api.getCoreWebsiteInfo(ctx)
 	return err == nil
 }
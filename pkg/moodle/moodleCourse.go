@@ -1,27 +1,63 @@
 package moodle
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/tidwall/gjson"
 )
 
-var errGradeRowIsNotGradeRow error = errors.New("row is not grade row")
+var (
+	errGradeRowIsNotGradeRow    error = errors.New("row is not grade row")
+	errGradeRowIsNotCategoryRow error = errors.New("row is not category row")
+)
+
+var levelRegex = regexp.MustCompile(`\blevel(\d+)\b`)
+
+const (
+	GradeRowItem          = "item"
+	GradeRowCategoryTotal = "categorytotal"
+	GradeRowCourseTotal   = "coursetotal"
+)
 
 type GradeReport struct {
-	ID           int
-	Title        string
-	Grade        string
-	Persentage   string
-	Feedback     string
-	Contribution string
-	Range        string
-	Weight       string
+	ID            int
+	Title         string
+	Grade         string
+	Persentage    string
+	Feedback      string
+	Contribution  string
+	Range         string
+	Weight        string
+	Type          string
+	ParentID      int
+	Level         int
+	URL           string
+	FeedbackFiles []string
+}
+
+// GradeCategory is a category row of the grades table. Items and totals
+// reference it by ParentID, the top category is the course itself.
+type GradeCategory struct {
+	ID       int
+	ParentID int
+	Level    int
+	Title    string
 }
 
+type GradeTable struct {
+	Grades     []GradeReport
+	Categories []GradeCategory
+	Warnings   []ParseWarning
+}
+
+// categoryLevels maps a table level to the last category seen on it.
+type categoryLevels map[int]int
+
 type MoodleUser struct {
 	api    moodleApi
 	userid string
@@ -32,54 +68,123 @@ func NewMoodleUser(api moodleApi, userid string) MoodleUser {
 }
 
 type moodleApi interface {
-	MoodleAPIRequest(string, map[string]string) ([]byte, error)
+	MoodleAPIRequest(context.Context, string, map[string]string) ([]byte, error)
 }
 
-func (mg MoodleUser) GetCourseGrades(courseid string) ([]GradeReport, error) {
+func (mg MoodleUser) GetCourseGrades(ctx context.Context, courseid string) (GradeTable, error) {
 	data := map[string]string{
 		"userid":   mg.userid,
 		"courseid": courseid,
 	}
 
-	gradesRes, err := mg.api.MoodleAPIRequest("gradereport_user_get_grades_table", data)
+	gradesRes, err := mg.api.MoodleAPIRequest(ctx, "gradereport_user_get_grades_table", data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get course grades: %v", err)
+		return GradeTable{}, fmt.Errorf("failed to get course grades: %w", err)
 	}
 
 	gradesJSON := string(gradesRes)
-	grades := mg.parseGradeTable(string(gradesJSON))
+	gradeTable, err := mg.parseGradeTable(string(gradesJSON))
+	if err != nil {
+		return GradeTable{}, ParseWarning{Row: -1, Err: err}
+	}
 
-	return grades, nil
+	return gradeTable, nil
 }
 
-func (mg MoodleUser) parseGradeTable(gradesJSON string) []GradeReport {
-	gradeRows := gjson.Get(gradesJSON, "tables.0.tabledata").Array()
-	gradesReport := []GradeReport{}
+// parseGradeTable skips rows it fails to parse and reports them in
+// GradeTable.Warnings, an error is returned only if there is no table at all.
+func (mg MoodleUser) parseGradeTable(gradesJSON string) (GradeTable, error) {
+	if !gjson.Valid(gradesJSON) {
+		return GradeTable{}, fmt.Errorf("failed to parse grades table: invalid json")
+	}
 
-	for _, gradeRow := range gradeRows {
+	tableData := gjson.Get(gradesJSON, "tables.0.tabledata")
+	if !tableData.IsArray() {
+		return GradeTable{}, fmt.Errorf("failed to parse grades table: no table data")
+	}
+
+	gradeTable := GradeTable{
+		Grades:     []GradeReport{},
+		Categories: []GradeCategory{},
+		Warnings:   []ParseWarning{},
+	}
+	levels := categoryLevels{}
 
-		gradeReport, err := mg.parseGradeRow(gradeRow)
+	for rowInx, gradeRow := range tableData.Array() {
+
+		category, err := mg.parseCategoryRow(gradeRow, levels)
+		if err == nil {
+			levels.set(category.Level, category.ID)
+			gradeTable.Categories = append(gradeTable.Categories, category)
+			continue
+		}
+		if !errors.Is(err, errGradeRowIsNotCategoryRow) {
+			gradeTable.Warnings = append(gradeTable.Warnings, mg.newRowWarning(rowInx, gradeRow, err))
+			continue
+		}
+
+		gradeReport, err := mg.parseGradeRow(gradeRow, levels)
 		if errors.Is(err, errGradeRowIsNotGradeRow) {
 			continue
 		}
 		if err != nil {
-			panic(err)
+			gradeTable.Warnings = append(gradeTable.Warnings, mg.newRowWarning(rowInx, gradeRow, err))
+			continue
 		}
 
-		gradesReport = append(gradesReport, gradeReport)
+		gradeTable.Grades = append(gradeTable.Grades, gradeReport)
+	}
+
+	return gradeTable, nil
+}
+
+func (mg MoodleUser) newRowWarning(rowInx int, gradeRow gjson.Result, err error) ParseWarning {
+	return ParseWarning{
+		Row:   rowInx,
+		RowID: gradeRow.Get("itemname.id").String(),
+		Err:   err,
+	}
+}
+
+func (mg MoodleUser) parseCategoryRow(
+	gradeRow gjson.Result,
+	levels categoryLevels,
+) (GradeCategory, error) {
+	idStr := gradeRow.Get("itemname.id").String()
+	if !strings.HasPrefix(idStr, "cat_") {
+		return GradeCategory{}, errGradeRowIsNotCategoryRow
+	}
+
+	id, err := mg.parseRowID(idStr)
+	if err != nil {
+		return GradeCategory{}, err
 	}
 
-	return gradesReport
+	level := mg.parseLevel(gradeRow.Get("itemname.class").String())
+
+	title := parseHTMLCell(gradeRow.Get("itemname.content").String()).Text
+
+	category := GradeCategory{
+		ID:       id,
+		ParentID: levels.parentOf(level),
+		Level:    level,
+		Title:    title,
+	}
+
+	return category, nil
 }
 
-func (mg MoodleUser) parseGradeRow(gradeRow gjson.Result) (GradeReport, error) {
-	titleUnparced := gradeRow.Get("itemname.content").String()
+func (mg MoodleUser) parseGradeRow(
+	gradeRow gjson.Result,
+	levels categoryLevels,
+) (GradeReport, error) {
+	itemCell := parseHTMLCell(gradeRow.Get("itemname.content").String())
 
-	if !mg.isRowContainsGrade(titleUnparced) {
+	if !itemCell.hasClass(gradeItemHeaderClass) {
 		return GradeReport{}, errGradeRowIsNotGradeRow
 	}
 
-	title := mg.parseTitle(titleUnparced)
+	title := mg.parseTitle(itemCell)
 
 	isTitleEmpty := strings.TrimSpace(title) == ""
 	if isTitleEmpty {
@@ -87,43 +192,128 @@ func (mg MoodleUser) parseGradeRow(gradeRow gjson.Result) (GradeReport, error) {
 	}
 
 	idStr := gradeRow.Get("itemname.id").String()
-	id, err := strconv.Atoi(mg.getStringBetween(idStr, "_", "_"))
+	id, err := mg.parseRowID(idStr)
 	if err != nil {
-		panic(err)
+		return GradeReport{}, err
 	}
 
-	unparsedGrade := gradeRow.Get("grade.content").String()
-	grade := mg.parseGrade(unparsedGrade)
+	gradeCell := parseHTMLCell(gradeRow.Get("grade.content").String())
+	grade := mg.parseGrade(gradeCell)
 
-	unparsedPercentage := gradeRow.Get("percentage.content").String()
-	percentage := mg.parsePersentage(unparsedPercentage)
+	percentageCell := parseHTMLCell(gradeRow.Get("percentage.content").String())
+	percentage := mg.parsePersentage(percentageCell)
 
-	weight := gradeRow.Get("weight.content").String()
+	weight := parseHTMLCell(gradeRow.Get("weight.content").String()).Text
 
-	contributionToCourse := gradeRow.Get("contributiontocoursetotal.content").String()
+	contributionCell := parseHTMLCell(gradeRow.Get("contributiontocoursetotal.content").String())
+	contributionToCourse := contributionCell.Text
 
-	rangeUnparced := gradeRow.Get("range.content").String()
-	gradeRange := mg.parseRange(rangeUnparced)
+	rangeCell := parseHTMLCell(gradeRow.Get("range.content").String())
+	gradeRange := mg.parseRange(rangeCell)
 
-	feedbackUnparced := gradeRow.Get("feedback.content").String()
-	feedback := mg.parseFeedback(feedbackUnparced)
+	feedbackCell := parseHTMLCell(gradeRow.Get("feedback.content").String())
+	feedback := mg.parseFeedback(feedbackCell)
+
+	itemClass := gradeRow.Get("itemname.class").String()
+	level := mg.parseLevel(itemClass)
+	rowType := mg.parseRowType(itemClass, level)
+
+	parentID := levels.parentOf(level)
+	isTotal := rowType != GradeRowItem
+	if isTotal {
+		parentID = levels.categoryOf(level)
+	}
 
 	gradeReport := GradeReport{
-		ID:           id,
-		Title:        title,
-		Grade:        grade,
-		Persentage:   percentage,
-		Feedback:     feedback,
-		Contribution: contributionToCourse,
-		Range:        gradeRange,
-		Weight:       weight,
+		ID:            id,
+		Title:         title,
+		Grade:         grade,
+		Persentage:    percentage,
+		Feedback:      feedback,
+		Contribution:  contributionToCourse,
+		Range:         gradeRange,
+		Weight:        weight,
+		Type:          rowType,
+		ParentID:      parentID,
+		Level:         level,
+		URL:           mg.parseItemURL(itemCell),
+		FeedbackFiles: feedbackCell.Links,
 	}
 
 	return gradeReport, nil
 }
 
-func (mg MoodleUser) parseGrade(unparsedGrade string) string {
-	grade := mg.removeTags(unparsedGrade)
+// parseRowID extracts the id from row ids like "row_<id>_<userid>" and
+// "cat_<id>_<userid>".
+func (mg MoodleUser) parseRowID(idStr string) (int, error) {
+	id, err := strconv.Atoi(mg.getStringBetween(idStr, "_", "_"))
+	if err != nil {
+		return 0, fmt.Errorf("bad row id %q", idStr)
+	}
+
+	return id, nil
+}
+
+func (mg MoodleUser) parseLevel(itemClass string) int {
+	levelMatches := levelRegex.FindStringSubmatch(itemClass)
+	if len(levelMatches) < 2 {
+		return 0
+	}
+
+	level, err := strconv.Atoi(levelMatches[1])
+	if err != nil {
+		return 0
+	}
+
+	return level
+}
+
+// parseRowType relies on moodle marking aggregation rows with "baggt", the
+// course total is the only aggregation on the first level.
+func (mg MoodleUser) parseRowType(itemClass string, level int) string {
+	if !strings.Contains(itemClass, "baggt") {
+		return GradeRowItem
+	}
+
+	if level <= 1 {
+		return GradeRowCourseTotal
+	}
+
+	return GradeRowCategoryTotal
+}
+
+func (levels categoryLevels) set(level int, categoryID int) {
+	for l := range levels {
+		if l > level {
+			delete(levels, l)
+		}
+	}
+
+	levels[level] = categoryID
+}
+
+func (levels categoryLevels) parentOf(level int) int {
+	for l := level - 1; l > 0; l-- {
+		if categoryID, ok := levels[l]; ok {
+			return categoryID
+		}
+	}
+
+	return 0
+}
+
+// categoryOf returns the category a total row aggregates, moodle renders
+// totals on the level of their category.
+func (levels categoryLevels) categoryOf(level int) int {
+	if categoryID, ok := levels[level]; ok {
+		return categoryID
+	}
+
+	return levels.parentOf(level)
+}
+
+func (mg MoodleUser) parseGrade(gradeCell htmlCell) string {
+	grade := gradeCell.Text
 
 	if grade == "Error" || grade == "-" {
 		return ""
@@ -132,8 +322,8 @@ func (mg MoodleUser) parseGrade(unparsedGrade string) string {
 	return grade
 }
 
-func (mg MoodleUser) parsePersentage(unparsedPersentage string) string {
-	persentage := mg.removeTags(unparsedPersentage)
+func (mg MoodleUser) parsePersentage(persentageCell htmlCell) string {
+	persentage := persentageCell.Text
 
 	if persentage == "Error" || persentage == "-" {
 		return ""
@@ -142,24 +332,44 @@ func (mg MoodleUser) parsePersentage(unparsedPersentage string) string {
 	return persentage
 }
 
-func (mg MoodleUser) parseFeedback(feedbackUnparced string) string {
-	feedback := mg.removeTags(feedbackUnparced)
+// parseFeedback treats a lone dash as empty, moodle shows it for items
+// without feedback.
+func (mg MoodleUser) parseFeedback(feedbackCell htmlCell) string {
+	feedback := feedbackCell.Text
 
-	feedback = strings.ReplaceAll(feedback, "&ndash;", "")
-	return strings.ReplaceAll(feedback, "&nbsp;", "")
+	if feedback == "–" || feedback == "-" {
+		return ""
+	}
+
+	return feedback
 }
 
-func (mg MoodleUser) parseRange(rangeUnparced string) string {
-	return strings.ReplaceAll(rangeUnparced, "&ndash;", "-")
+func (mg MoodleUser) parseRange(rangeCell htmlCell) string {
+	return strings.ReplaceAll(rangeCell.Text, "–", "-")
 }
 
-func (mg MoodleUser) isRowContainsGrade(row string) bool {
-	return strings.Contains(row, "class=\"gradeitemheader\"")
+func (mg MoodleUser) parseTitle(itemCell htmlCell) string {
+	if itemCell.HeaderTitle != "" {
+		return itemCell.HeaderTitle
+	}
+
+	if itemCell.Title != "" {
+		return itemCell.Title
+	}
+
+	return itemCell.Text
 }
 
-func (mg MoodleUser) parseTitle(unparsedTitle string) string {
-	title := mg.getStringBetween(unparsedTitle, "title=\"", "\"")
-	return title
+func (mg MoodleUser) parseItemURL(itemCell htmlCell) string {
+	if itemCell.HeaderLink != "" {
+		return itemCell.HeaderLink
+	}
+
+	if len(itemCell.Links) > 0 {
+		return itemCell.Links[0]
+	}
+
+	return ""
 }
 
 func (mg MoodleUser) getStringBetween(str string, startS string, endS string) string {
@@ -175,17 +385,3 @@ func (mg MoodleUser) getStringBetween(str string, startS string, endS string) st
 	result := newS[:e]
 	return result
 }
-
-func (mg MoodleUser) removeTags(str string) string {
-	for strings.Contains(str, "<") {
-		start := strings.Index(str, "<")
-		end := strings.Index(str, ">")
-
-		before := str[:start]
-		after := str[end+1:]
-
-		str = before + after
-	}
-
-	return str
-}
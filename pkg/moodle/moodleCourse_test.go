@@ -0,0 +1,165 @@
+package moodle
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixtureAPI struct {
+	fixtures map[string]string
+}
+
+func (api fixtureAPI) MoodleAPIRequest(_ context.Context, function string, _ map[string]string) ([]byte, error) {
+	return os.ReadFile(api.fixtures[function])
+}
+
+const gradeTreeJSON = `{"tables": [{"tabledata": [
+	{"itemname": {"class": "level1 levelodd category column-itemname", "content": "AGLA", "id": "cat_10_7"}},
+	{"itemname": {"class": "level2 leveleven category column-itemname", "content": "Labs", "id": "cat_11_7"}},
+	{"itemname": {"class": "level3 levelodd item b1b column-itemname", "content": "<a title=\"Lab 4\" class=\"gradeitemheader\" href=\"#\">Lab 4</a>", "id": "row_101_7"}, "grade": {"content": "8.00"}},
+	{"itemname": {"class": "level2 leveleven baggt b2b column-itemname", "content": "<span class=\"gradeitemheader\" title=\"Labs total\">Labs total</span>", "id": "row_102_7"}, "grade": {"content": "8.00"}},
+	{"itemname": {"class": "level2 leveleven item b1b column-itemname", "content": "<a title=\"Final exam\" class=\"gradeitemheader\" href=\"#\">Final exam</a>", "id": "row_103_7"}, "grade": {"content": "-"}},
+	{"itemname": {"class": "level1 levelodd baggt b2b column-itemname", "content": "<span class=\"gradeitemheader\" title=\"Course total\">Course total</span>", "id": "row_104_7"}, "grade": {"content": "8.00"}}
+]}]}`
+
+func TestParseGradeTree(t *testing.T) {
+	mg := MoodleUser{}
+	gradeTable, err := mg.parseGradeTable(gradeTreeJSON)
+	assert.NoError(t, err)
+
+	t.Run("categories", func(t *testing.T) {
+		expected := []GradeCategory{
+			{ID: 10, ParentID: 0, Level: 1, Title: "AGLA"},
+			{ID: 11, ParentID: 10, Level: 2, Title: "Labs"},
+		}
+		assert.Equal(t, expected, gradeTable.Categories)
+	})
+
+	t.Run("grade rows", func(t *testing.T) {
+		type row struct {
+			ID       int
+			Type     string
+			ParentID int
+			Level    int
+		}
+
+		rows := []row{}
+		for _, grade := range gradeTable.Grades {
+			rows = append(rows, row{grade.ID, grade.Type, grade.ParentID, grade.Level})
+		}
+
+		expected := []row{
+			{101, GradeRowItem, 11, 3},
+			{102, GradeRowCategoryTotal, 11, 2},
+			{103, GradeRowItem, 10, 2},
+			{104, GradeRowCourseTotal, 10, 1},
+		}
+		assert.Equal(t, expected, rows)
+	})
+
+	t.Run("grade path", func(t *testing.T) {
+		course := Course{Grades: gradeTable.Grades, Categories: gradeTable.Categories}
+
+		assert.Equal(t, []string{"Labs"}, course.GradePath(gradeTable.Grades[0]))
+		assert.Equal(t, []string{}, course.GradePath(gradeTable.Grades[1]))
+		assert.Equal(t, []string{}, course.GradePath(gradeTable.Grades[2]))
+	})
+}
+
+func TestGetCourseGradesFixture(t *testing.T) {
+	api := fixtureAPI{map[string]string{
+		"gradereport_user_get_grades_table": "testdata/gradereport_user_get_grades_table.json",
+	}}
+
+	gradeTable, err := NewMoodleUser(api, "7").GetCourseGrades(context.Background(), "42")
+	assert.NoError(t, err)
+
+	t.Run("categories", func(t *testing.T) {
+		expected := []GradeCategory{
+			{ID: 310, ParentID: 0, Level: 1, Title: "[S23] Analytical Geometry & Linear Algebra II"},
+			{ID: 311, ParentID: 310, Level: 2, Title: "Labs"},
+		}
+		assert.Equal(t, expected, gradeTable.Categories)
+	})
+
+	t.Run("graded item", func(t *testing.T) {
+		expected := GradeReport{
+			ID:            1501,
+			Title:         "Link to Assignment activity Lab 4",
+			Grade:         "8.00",
+			Persentage:    "80.00 %",
+			Feedback:      "Good job — see the remarks.\n\nCheck lab4.pdf",
+			Contribution:  "4.00 %",
+			Range:         "0-10",
+			Weight:        "50.00 %",
+			Type:          GradeRowItem,
+			ParentID:      311,
+			Level:         3,
+			URL:           "https://moodle.innopolis.university/mod/assign/view.php?id=1204",
+			FeedbackFiles: []string{"https://moodle.innopolis.university/pluginfile.php/55/assignfeedback_file/feedback_files/9/lab4.pdf"},
+		}
+		assert.Equal(t, expected, gradeTable.Grades[0])
+	})
+
+	t.Run("ungraded item", func(t *testing.T) {
+		grade := gradeTable.Grades[1]
+
+		assert.Equal(t, "Link to Quiz activity Quiz 1 <draft>", grade.Title)
+		assert.Equal(t, "", grade.Grade)
+		assert.Equal(t, "", grade.Persentage)
+		assert.Equal(t, "", grade.Feedback)
+		assert.Equal(t, []string{}, grade.FeedbackFiles)
+	})
+
+	t.Run("totals", func(t *testing.T) {
+		categoryTotal := gradeTable.Grades[2]
+		courseTotal := gradeTable.Grades[3]
+
+		assert.Len(t, gradeTable.Grades, 4)
+		assert.Equal(t, "Labs total", categoryTotal.Title)
+		assert.Equal(t, GradeRowCategoryTotal, categoryTotal.Type)
+		assert.Equal(t, 311, categoryTotal.ParentID)
+		assert.Equal(t, "Course total", courseTotal.Title)
+		assert.Equal(t, "3.20", courseTotal.Grade)
+		assert.Equal(t, GradeRowCourseTotal, courseTotal.Type)
+		assert.Equal(t, 310, courseTotal.ParentID)
+	})
+}
+
+func TestParseMalformedGradeTable(t *testing.T) {
+	t.Run("malformed rows are skipped", func(t *testing.T) {
+		gradesJSON := `{"tables": [{"tabledata": [
+			{"itemname": {"class": "level1 levelodd category", "content": "AGLA", "id": "cat_x_7"}},
+			{"itemname": {"class": "level2 item", "content": "<a title=\"Lab 1\" class=\"gradeitemheader\">Lab 1</a>", "id": "row101"}},
+			{"itemname": {"class": "level2 item", "content": "<a title=\"Lab 2\" class=\"gradeitemheader\">Lab 2</a>", "id": "row_102_7"}}
+		]}]}`
+
+		mg := MoodleUser{}
+		gradeTable, err := mg.parseGradeTable(gradesJSON)
+
+		assert.NoError(t, err)
+		assert.Len(t, gradeTable.Grades, 1)
+		assert.Equal(t, 102, gradeTable.Grades[0].ID)
+		assert.Len(t, gradeTable.Warnings, 2)
+		assert.Equal(t, 0, gradeTable.Warnings[0].Row)
+		assert.Equal(t, "cat_x_7", gradeTable.Warnings[0].RowID)
+		assert.Equal(t, 1, gradeTable.Warnings[1].Row)
+		assert.Equal(t, "row101", gradeTable.Warnings[1].RowID)
+	})
+
+	t.Run("invalid table", func(t *testing.T) {
+		api := fixtureAPI{map[string]string{
+			"gradereport_user_get_grades_table": "testdata/invalid.json",
+		}}
+
+		_, err := NewMoodleUser(api, "7").GetCourseGrades(context.Background(), "42")
+
+		var warning ParseWarning
+		assert.True(t, errors.As(err, &warning))
+		assert.Equal(t, -1, warning.Row)
+	})
+}
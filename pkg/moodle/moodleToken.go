@@ -1,27 +1,40 @@
 package moodle
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/dump"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/metrics"
 )
 
-func GetTokens(credentialsPath string, logger *log.Logger) (MoodleToken, error) {
-	cookieRequestManager, err := newCookieRequest(credentialsPath, logger)
+// GetTokens returns the stored token if moodle accepts it, otherwise a new
+// one got by logging in. Unexpected SSO responses are kept in dumps.
+func GetTokens(
+	ctx context.Context,
+	client *Client,
+	credentials Credentials,
+	dumps dump.Dumps,
+	logger *slog.Logger,
+) (MoodleToken, error) {
+	cookieRequestManager, err := newCookieRequest(credentials, dumps, logger)
 	if err != nil {
 		return "", err
 	}
 
-	loginCredentials, err := cookieRequestManager.requestNewTokens()
+	loginCredentials, err := credentials.get()
 	if err != nil {
-		return "", fmt.Errorf("failed to get old cookies: %v", err)
+		return "", fmt.Errorf("failed to get old token: %w", err)
 	}
-	oldToken := MoodleToken(loginCredentials)
+	oldToken := MoodleToken(loginCredentials.Token)
 
-	if check(oldToken, logger) {
+	if oldToken != "" && check(ctx, client.WithToken(oldToken), logger) {
 		return oldToken, nil
 	}
 
-	tokens, err := cookieRequestManager.requestNewTokens()
+	tokens, err := cookieRequestManager.requestNewTokens(ctx)
+	metrics.TokenRefreshed(err)
 	if err != nil {
 		return "", err
 	}
@@ -29,10 +42,10 @@ func GetTokens(credentialsPath string, logger *log.Logger) (MoodleToken, error)
 	return tokens, nil
 }
 
-func check(token MoodleToken, logger *log.Logger) bool {
-	api, err := NewMoodle(token, logger)
+func check(ctx context.Context, client *Client, logger *slog.Logger) bool {
+	api, err := NewMoodle(ctx, client, DefaultFetchConfig(), logger)
 	if err != nil {
 		return false
 	}
-	return api.isTokenGood()
+	return api.isTokenGood(ctx)
 }
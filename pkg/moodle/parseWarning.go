@@ -0,0 +1,40 @@
+package moodle
+
+import "fmt"
+
+// ParseWarning describes a part of the grades of a course that could not be
+// parsed. Row is the index of the row in the grades table, it is -1 when the
+// whole table is affected.
+type ParseWarning struct {
+	CourseID   int
+	CourseName string
+	Row        int
+	RowID      string
+	Err        error
+}
+
+func (w ParseWarning) Error() string {
+	course := w.CourseName
+	if course == "" {
+		course = fmt.Sprint(w.CourseID)
+	}
+
+	if w.Row < 0 {
+		return fmt.Sprintf("course %q: %v", course, w.Err)
+	}
+
+	return fmt.Sprintf("course %q, row %d (%q): %v", course, w.Row, w.RowID, w.Err)
+}
+
+func (w ParseWarning) Unwrap() error {
+	return w.Err
+}
+
+func CollectWarnings(courses []Course) []ParseWarning {
+	warnings := []ParseWarning{}
+	for _, course := range courses {
+		warnings = append(warnings, course.Warnings...)
+	}
+
+	return warnings
+}
@@ -2,9 +2,12 @@ package formatter
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
 	"golang.org/x/exp/slices"
 )
 
@@ -21,11 +24,18 @@ func ConvertCourseGradesChange(
 		formatterTableChange := []GradeRowChange{}
 		for _, historyTableChage := range change.GradesTableChange {
 
+			changedRow := historyTableChage.To
+			if historyTableChage.Type == "remove" {
+				changedRow = historyTableChage.From
+			}
+
 			formatterRowChange := GradeRowChange{
 				Type:   historyTableChage.Type,
 				Fields: historyTableChage.Fields,
 				From:   GradeReport(historyTableChage.From),
 				To:     GradeReport(historyTableChage.To),
+				Delta:  GradeDelta(historyTableChage.Delta),
+				Path:   change.Course.GradePath(changedRow),
 			}
 			formatterTableChange = append(formatterTableChange, formatterRowChange)
 		}
@@ -33,6 +43,12 @@ func ConvertCourseGradesChange(
 		newGradeChange := CourseGradesChange{
 			Course:            Course{Fullname: change.Course.Fullname},
 			GradesTableChange: formatterTableChange,
+			TotalChange: CourseTotalChange{
+				Type:  change.TotalChange.Type,
+				From:  change.TotalChange.From.Grade,
+				To:    change.TotalChange.To.Grade,
+				Delta: GradeDelta(change.TotalChange.Delta),
+			},
 		}
 		formatterGrades = append(formatterGrades, newGradeChange)
 	}
@@ -40,6 +56,40 @@ func ConvertCourseGradesChange(
 	return formatterGrades
 }
 
+// ConvertCourses converts the tracked courses for the overview.
+func ConvertCourses(moodleCourses []moodle.Course) []Course {
+	courses := make([]Course, 0, len(moodleCourses))
+	for _, moodleCourse := range moodleCourses {
+		if moodleCourse.Untracked {
+			continue
+		}
+
+		courses = append(courses, Course{
+			Fullname: moodleCourse.Fullname,
+			Total:    moodleCourse.Total.Grade,
+		})
+	}
+
+	return courses
+}
+
+func ConvertWarnings(parseWarnings []moodle.ParseWarning) []Warning {
+	warnings := make([]Warning, 0, len(parseWarnings))
+	for _, parseWarning := range parseWarnings {
+		message := parseWarning.Err.Error()
+		if parseWarning.Row >= 0 {
+			message = fmt.Sprintf("row %d (%q): %v", parseWarning.Row, parseWarning.RowID, parseWarning.Err)
+		}
+
+		warnings = append(warnings, Warning{
+			Course:  parseWarning.CourseName,
+			Message: message,
+		})
+	}
+
+	return warnings
+}
+
 func NewFormatter(cfg FormatConfig) Formatter {
 	return Formatter{cfg}
 }
@@ -50,15 +100,30 @@ type FormatConfig struct {
 	UpdatesToCheck   []string
 	ToCheckCreates   bool
 	ToCheckRemoves   bool
+	IgnoreTotals     bool
 }
 
 type CourseGradesChange struct {
 	Course            Course
 	GradesTableChange []GradeRowChange
+	TotalChange       CourseTotalChange
 }
 
 type Course struct {
 	Fullname string `json:"fullname"`
+	Total    string `json:"total"`
+}
+
+type Warning struct {
+	Course  string
+	Message string
+}
+
+type CourseTotalChange struct {
+	Type  string
+	From  string
+	To    string
+	Delta GradeDelta
 }
 
 type GradeRowChange struct {
@@ -66,17 +131,31 @@ type GradeRowChange struct {
 	Fields []string
 	From   GradeReport
 	To     GradeReport
+	Delta  GradeDelta
+	Path   []string
+}
+
+type GradeDelta struct {
+	Points        float64
+	HasPoints     bool
+	Percentage    float64
+	HasPercentage bool
 }
 
 type GradeReport struct {
-	ID           int
-	Title        string
-	Grade        string
-	Persentage   string
-	Feedback     string
-	Contribution string
-	Range        string
-	Weight       string
+	ID            int
+	Title         string
+	Grade         string
+	Persentage    string
+	Feedback      string
+	Contribution  string
+	Range         string
+	Weight        string
+	Type          string
+	ParentID      int
+	Level         int
+	URL           string
+	FeedbackFiles []string
 }
 
 func (f Formatter) ConvertUpdatesToString(
@@ -90,11 +169,16 @@ func (f Formatter) ConvertUpdatesToString(
 		courseTitle := f.getCourseTitle(courseChange.Course.Fullname)
 		courseRelatedMessages = append(courseRelatedMessages, courseTitle+"\n\n")
 
+		totalChange := f.convertTotalChangeToString(courseChange.TotalChange)
+		if totalChange != "" {
+			courseRelatedMessages = append(courseRelatedMessages, totalChange+"\n\n")
+		}
+
 		gradesChanges, err := f.parseGradeTable(courseChange.GradesTableChange)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert updates for print: %v", err)
 		}
-		if len(gradesChanges) == 0 {
+		if len(gradesChanges) == 0 && totalChange == "" {
 			continue
 		}
 
@@ -108,19 +192,58 @@ func (f Formatter) ConvertUpdatesToString(
 	return messages, nil
 }
 
+func (f Formatter) ConvertOverviewToString(courses []Course, maxMsgLengh int) []string {
+	overviewPieces := make([]string, 0, len(courses)+1)
+	overviewPieces = append(overviewPieces, "Overview:\n\n")
+
+	for _, course := range courses {
+		total := course.Total
+		if total == "" {
+			total = "-"
+		}
+
+		overviewPieces = append(
+			overviewPieces,
+			fmt.Sprintf("%s  %q\n", f.getCourseTitle(course.Fullname), total),
+		)
+	}
+
+	return f.concatenate(overviewPieces, maxMsgLengh)
+}
+
+func (f Formatter) ConvertWarningsToString(warnings []Warning, maxMsgLengh int) []string {
+	warningPieces := make([]string, 0, len(warnings)+1)
+	warningPieces = append(warningPieces, "Parser warning:\n\n")
+
+	for _, warning := range warnings {
+		warningPieces = append(
+			warningPieces,
+			fmt.Sprintf("%s\n%s\n\n", f.getCourseTitle(warning.Course), warning.Message),
+		)
+	}
+
+	return f.concatenate(warningPieces, maxMsgLengh)
+}
+
 func (f Formatter) FilterGradesChanges(courseChanges []CourseGradesChange) []CourseGradesChange {
 	filteredCourseChange := []CourseGradesChange{}
 	for _, courseChange := range courseChanges {
 
 		filteredGradeChange := f.filterGradeRows(courseChange.GradesTableChange)
 
-		if len(filteredGradeChange) == 0 {
+		totalChange := courseChange.TotalChange
+		if f.cfg.IgnoreTotals {
+			totalChange = CourseTotalChange{Type: "nochange"}
+		}
+
+		if len(filteredGradeChange) == 0 && totalChange.Type != "update" {
 			continue
 		}
 
 		newCourseGradesChange := CourseGradesChange{
 			Course:            courseChange.Course,
 			GradesTableChange: filteredGradeChange,
+			TotalChange:       totalChange,
 		}
 		filteredCourseChange = append(filteredCourseChange, newCourseGradesChange)
 	}
@@ -138,6 +261,11 @@ func (f Formatter) filterGradeRows(gradeRows []GradeRowChange) []GradeRowChange
 			continue
 		}
 
+		isTotalNotTracked := f.cfg.IgnoreTotals && f.getRowType(gradeChange) != moodle.GradeRowItem
+		if isTotalNotTracked {
+			continue
+		}
+
 		filteredGradeChange = append(filteredGradeChange, gradeChange)
 	}
 
@@ -180,6 +308,19 @@ func (f Formatter) getCourseTitle(courseName string) string {
 	return fmt.Sprintf("%s:", courseName)
 }
 
+func (f Formatter) convertTotalChangeToString(totalChange CourseTotalChange) string {
+	if totalChange.Type != "update" {
+		return ""
+	}
+
+	totalChangeMsg := fmt.Sprintf("Course total:  %q  ->  %q", totalChange.From, totalChange.To)
+	if totalChange.Delta.HasPoints {
+		totalChangeMsg += fmt.Sprintf("  (%s)", f.formatDelta(totalChange.Delta))
+	}
+
+	return totalChangeMsg
+}
+
 func (f Formatter) parseGradeTable(gradeChanges []GradeRowChange) ([]string, error) {
 	changes := make([]string, 0, len(gradeChanges))
 
@@ -219,12 +360,23 @@ func (f Formatter) convertGradeChangeToString(rowChanges GradeRowChange) (string
 		changesStr.WriteString("\n")
 	}
 
+	switch f.getRowType(rowChanges) {
+	case moodle.GradeRowCategoryTotal:
+		changesStr.WriteString("(category total)")
+		changesStr.WriteString("\n")
+	case moodle.GradeRowCourseTotal:
+		changesStr.WriteString("(course total)")
+		changesStr.WriteString("\n")
+	}
+
 	for _, fieldToPrint := range f.cfg.ToPrint {
 		changed := slices.Contains(rowChanges.Fields, fieldToPrint)
 
 		fieldChange, err := f.convertGradeField(
 			rowChanges.From,
 			rowChanges.To,
+			rowChanges.Delta,
+			rowChanges.Path,
 			fieldToPrint,
 			changed,
 		)
@@ -247,6 +399,8 @@ func (f Formatter) convertGradeChangeToString(rowChanges GradeRowChange) (string
 		fieldChange, err := f.convertGradeField(
 			rowChanges.From,
 			rowChanges.To,
+			rowChanges.Delta,
+			rowChanges.Path,
 			fieldToPrint,
 			changed,
 		)
@@ -268,6 +422,8 @@ func (f Formatter) checkChangedToPrint() bool {
 
 func (f Formatter) convertGradeField(
 	from, to GradeReport,
+	delta GradeDelta,
+	path []string,
 	field string,
 	changed bool,
 ) (string, error) {
@@ -281,6 +437,12 @@ func (f Formatter) convertGradeField(
 		return "", err
 	}
 
+	isTitleInCategory := field == "Title" && len(path) > 0
+	if isTitleInCategory {
+		fieldValueTo = f.joinPath(path, fieldValueTo)
+		fieldValueFrom = f.joinPath(path, fieldValueFrom)
+	}
+
 	fieldChangeMsg := ""
 	if !changed {
 		fieldChangeMsg = fmt.Sprintf("%s:  %q", field, fieldValueTo)
@@ -288,15 +450,91 @@ func (f Formatter) convertGradeField(
 		fieldChangeMsg = fmt.Sprintf("%s:  %q  ->  %q", field, fieldValueFrom, fieldValueTo)
 	}
 
+	isGradeDeltaKnown := field == "Grade" && changed && (delta.HasPoints || delta.HasPercentage)
+	if isGradeDeltaKnown {
+		fieldChangeMsg += fmt.Sprintf("  (%s)", f.formatDelta(delta))
+	}
+
 	return fieldChangeMsg, nil
 }
 
+func (f Formatter) joinPath(path []string, title string) string {
+	return strings.Join(append(slices.Clone(path), title), " › ")
+}
+
+func (f Formatter) getRowType(gradeChange GradeRowChange) string {
+	row := gradeChange.To
+	if gradeChange.Type == "remove" {
+		row = gradeChange.From
+	}
+
+	if row.Type == "" {
+		return moodle.GradeRowItem
+	}
+
+	return row.Type
+}
+
+func (f Formatter) formatDelta(delta GradeDelta) string {
+	deltas := make([]string, 0, 2)
+
+	if delta.HasPoints {
+		deltas = append(deltas, fmt.Sprintf("%s pts", f.formatSignedNumber(delta.Points)))
+	}
+
+	if delta.HasPercentage {
+		deltas = append(deltas, fmt.Sprintf("%s %%", f.formatSignedNumber(delta.Percentage)))
+	}
+
+	return strings.Join(deltas, ", ")
+}
+
+func (f Formatter) formatGrade(gradeReport GradeReport) string {
+	values := moodle.GradeReport(gradeReport).Values()
+
+	hasNumericRange := values.Grade.IsNumeric() && values.Range.Kind == moodle.GradeValueRange
+	if !hasNumericRange {
+		return gradeReport.Grade
+	}
+
+	grade := fmt.Sprintf(
+		"%s / %s",
+		f.formatNumber(values.Grade.Number),
+		f.formatNumber(values.Range.Max),
+	)
+
+	percentage := values.Persentage.Number
+	if !values.Persentage.IsPercentage() {
+		rangeLength := values.Range.Max - values.Range.Min
+		if rangeLength <= 0 {
+			return grade
+		}
+
+		percentage = (values.Grade.Number - values.Range.Min) / rangeLength * 100
+	}
+
+	return fmt.Sprintf("%s (%s %%)", grade, f.formatNumber(percentage))
+}
+
+func (f Formatter) formatNumber(number float64) string {
+	rounded := math.Round(number*100) / 100
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+func (f Formatter) formatSignedNumber(number float64) string {
+	if number > 0 {
+		return "+" + f.formatNumber(number)
+	}
+
+	return f.formatNumber(number)
+}
+
 func (f Formatter) getFieldValue(field string, gradeReport GradeReport) (string, error) {
 	switch field {
 	case "Title":
 		return gradeReport.Title, nil
 	case "Grade":
-		return gradeReport.Grade, nil
+		return f.formatGrade(gradeReport), nil
 	case "Persentage":
 		return gradeReport.Persentage, nil
 	case "Feedback":
@@ -307,6 +545,18 @@ func (f Formatter) getFieldValue(field string, gradeReport GradeReport) (string,
 		return gradeReport.Weight, nil
 	case "Contribution":
 		return gradeReport.Contribution, nil
+	case "URL":
+		return gradeReport.URL, nil
+	case "FeedbackFiles":
+		return strings.Join(gradeReport.FeedbackFiles, "\n"), nil
+	case "ID":
+		return strconv.Itoa(gradeReport.ID), nil
+	case "Type":
+		return gradeReport.Type, nil
+	case "ParentID":
+		return strconv.Itoa(gradeReport.ParentID), nil
+	case "Level":
+		return strconv.Itoa(gradeReport.Level), nil
 	default:
 		return "", fmt.Errorf("bad field name to print = %q", field)
 	}
@@ -0,0 +1,72 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatGrade(t *testing.T) {
+	cases := []struct {
+		name     string
+		grade    GradeReport
+		expected string
+	}{
+		{"percentage of the row", GradeReport{Grade: "8.00", Persentage: "80.00 %", Range: "0–10"}, "8 / 10 (80 %)"},
+		{"percentage from the range", GradeReport{Grade: "7,5", Range: "0–10"}, "7.5 / 10 (75 %)"},
+		{"range not from zero", GradeReport{Grade: "3", Range: "2–4"}, "3 / 4 (50 %)"},
+		{"rounded numbers", GradeReport{Grade: "2", Range: "0–3"}, "2 / 3 (66.67 %)"},
+		{"empty range", GradeReport{Grade: "5", Range: "5–5"}, "5 / 5"},
+		{"no range", GradeReport{Grade: "8.00"}, "8.00"},
+		{"letter grade", GradeReport{Grade: "B+", Range: "F–A"}, "B+"},
+		{"absent grade", GradeReport{Grade: "-", Range: "0–10"}, "-"},
+	}
+
+	f := NewFormatter(FormatConfig{})
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, f.formatGrade(c.grade))
+		})
+	}
+}
+
+func TestFormatDelta(t *testing.T) {
+	cases := []struct {
+		name     string
+		delta    GradeDelta
+		expected string
+	}{
+		{"points and percentage", GradeDelta{Points: 1, HasPoints: true, Percentage: 10, HasPercentage: true}, "+1 pts, +10 %"},
+		{"negative points", GradeDelta{Points: -0.5, HasPoints: true}, "-0.5 pts"},
+		{"percentage only", GradeDelta{Percentage: 33.333, HasPercentage: true}, "+33.33 %"},
+		{"zero", GradeDelta{HasPoints: true}, "0 pts"},
+		{"unknown", GradeDelta{}, ""},
+	}
+
+	f := NewFormatter(FormatConfig{})
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, f.formatDelta(c.delta))
+		})
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     []string
+		title    string
+		expected string
+	}{
+		{"no categories", nil, "Lab 4", "Lab 4"},
+		{"one category", []string{"Labs"}, "Lab 4", "Labs › Lab 4"},
+		{"nested categories", []string{"Assignments", "Labs"}, "Lab 4", "Assignments › Labs › Lab 4"},
+	}
+
+	f := NewFormatter(FormatConfig{})
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, f.joinPath(c.path, c.title))
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package notifyer
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"time"
 
 	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/metrics"
 	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/formatter"
 	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/telegram"
 )
@@ -15,32 +20,74 @@ type Notifyer struct {
 	service                  Service
 	formatter                Formatter
 	lastTimeNotifyedFilePath string
+	sentWarnings             warningSet
+	sentUpdates              messageSet
+	name                     string
+	log                      *slog.Logger
 }
 
-func NewTelegramNotifyer(cfg config.Config) Notifyer {
-	tgService := telegram.NewTelegramService(
+type warningSet map[formatter.Warning]bool
+
+type messageSet map[string]bool
+
+func NewTelegramNotifyer(cfg config.Config) (Notifyer, error) {
+	tgService, err := telegram.NewTelegramService(
 		cfg.TelegramBotKey,
 		cfg.TelegramChatID,
 	)
+	if err != nil {
+		return Notifyer{}, err
+	}
+
+	return NewNotifyer(tgService, "telegram", newFormatter(cfg), cfg.LastTimeNotifyedPath, cfg.Logger), nil
+}
+
+// NewPrintNotifyer writes the messages to out instead of sending them, it is
+// used for dry runs.
+func NewPrintNotifyer(cfg config.Config, out io.Writer) Notifyer {
+	return NewNotifyer(printService{out}, "stdout", newFormatter(cfg), cfg.LastTimeNotifyedPath, cfg.Logger)
+}
 
+func newFormatter(cfg config.Config) formatter.Formatter {
 	formatterConfig := formatter.FormatConfig{
 		UpdatesToCheck:   cfg.UpdatesToCheck,
 		ToPrintOnUpdates: cfg.ToPrintOnUpdates,
 		ToPrint:          cfg.ToPrint,
 		ToCheckCreates:   false,
 		ToCheckRemoves:   false,
+		IgnoreTotals:     cfg.IgnoreTotals,
 	}
-	fmter := formatter.NewFormatter(formatterConfig)
 
-	return NewNotifyer(tgService, fmter, cfg.LastTimeNotifyedPath)
+	return formatter.NewFormatter(formatterConfig)
 }
 
+type printService struct {
+	out io.Writer
+}
+
+func (s printService) Send(ctx context.Context, msg string) error {
+	_, err := fmt.Fprintf(s.out, "%s\n\n", msg)
+	return err
+}
+
+// NewNotifyer sends the messages through service, name tells the service
+// apart in the logs and the metrics.
 func NewNotifyer(
 	service Service,
+	name string,
 	formatter Formatter,
 	lastTimeNotifyedFilePath string,
+	log *slog.Logger,
 ) Notifyer {
-	return Notifyer{service, formatter, lastTimeNotifyedFilePath}
+	return Notifyer{
+		service,
+		formatter,
+		lastTimeNotifyedFilePath,
+		warningSet{},
+		messageSet{},
+		name,
+		log.With(logging.KeyService, name),
+	}
 }
 
 func (tn *Notifyer) SaveLastTimeNotifyed(timeNotifyed time.Time) error {
@@ -79,7 +126,10 @@ func (tn Notifyer) GetLastTimeNotifyed() (time.Time, error) {
 	return lastTimeNotifyedTime, nil
 }
 
-func (tn *Notifyer) SendUpdates(updates []formatter.CourseGradesChange) (int, error) {
+// SendUpdates skips the messages sent before a failure when the same updates
+// are sent again, a message telegram rejects is skipped instead of failing
+// the messages after it.
+func (tn *Notifyer) SendUpdates(ctx context.Context, updates []formatter.CourseGradesChange) (int, error) {
 	filteredUpdates := tn.formatter.FilterGradesChanges(updates)
 
 	messages, err := tn.formatter.ConvertUpdatesToString(filteredUpdates, 4096)
@@ -87,18 +137,111 @@ func (tn *Notifyer) SendUpdates(updates []formatter.CourseGradesChange) (int, er
 		return 0, fmt.Errorf("failed to send updates: %v", err)
 	}
 
+	sent := 0
+	for _, msg := range messages {
+		if tn.sentUpdates[msg] {
+			continue
+		}
+
+		err = tn.send(ctx, "updates", msg)
+		if errors.Is(err, telegram.ErrRejected) {
+			tn.log.ErrorContext(ctx, "message is rejected, skipping it", logging.Err(err))
+			continue
+		}
+		if err != nil {
+			return sent, fmt.Errorf("failed to send updates: %v", err)
+		}
+
+		tn.sentUpdates[msg] = true
+		sent++
+	}
+
+	tn.sentUpdates = messageSet{}
+
+	return sent, nil
+}
+
+func (tn *Notifyer) SendOverview(ctx context.Context, courses []formatter.Course) (int, error) {
+	messages := tn.formatter.ConvertOverviewToString(courses, 4096)
+
+	for _, msg := range messages {
+		err := tn.send(ctx, "overview", msg)
+		if err != nil {
+			return 0, fmt.Errorf("failed to send overview: %v", err)
+		}
+	}
+
+	return len(messages), nil
+}
+
+// SendWarnings sends only the warnings that were not sent before, so a broken
+// row is reported once rather than on every check.
+func (tn *Notifyer) SendWarnings(ctx context.Context, warnings []formatter.Warning) (int, error) {
+	newWarnings := []formatter.Warning{}
+	for _, warning := range warnings {
+		if tn.sentWarnings[warning] {
+			continue
+		}
+
+		newWarnings = append(newWarnings, warning)
+	}
+
+	if len(newWarnings) == 0 {
+		return 0, nil
+	}
+
+	messages := tn.formatter.ConvertWarningsToString(newWarnings, 4096)
 	for _, msg := range messages {
-		err = tn.service.Send(msg)
+		err := tn.send(ctx, "warnings", msg)
 		if err != nil {
-			return 0, fmt.Errorf("failed to send updates: %v", err)
+			return 0, fmt.Errorf("failed to send warnings: %v", err)
 		}
 	}
 
+	for _, warning := range newWarnings {
+		tn.sentWarnings[warning] = true
+	}
+
 	return len(messages), nil
 }
 
+// KeepSent takes the warnings and the undelivered updates sent by previous,
+// e.g. the notifyer replaced on config reload, so they are not sent again.
+func (tn *Notifyer) KeepSent(previous *Notifyer) {
+	for warning := range previous.sentWarnings {
+		tn.sentWarnings[warning] = true
+	}
+	for msg := range previous.sentUpdates {
+		tn.sentUpdates[msg] = true
+	}
+}
+
+// SendAlert sends a message about the scrapper itself, like failing checks.
+func (tn *Notifyer) SendAlert(ctx context.Context, msg string) error {
+	err := tn.send(ctx, "alert", msg)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %v", err)
+	}
+
+	return nil
+}
+
+// send sends a message of kind, like updates or alert, through the service.
+func (tn *Notifyer) send(ctx context.Context, kind, msg string) error {
+	start := time.Now()
+	err := tn.service.Send(ctx, msg)
+	metrics.MessageSent(tn.name, kind, err)
+	if err != nil {
+		tn.log.WarnContext(ctx, "failed to send message", "kind", kind, logging.KeyDuration, time.Since(start), logging.Err(err))
+		return err
+	}
+	tn.log.DebugContext(ctx, "sent message", "kind", kind, "length", len(msg), logging.KeyDuration, time.Since(start))
+
+	return nil
+}
+
 type Service interface {
-	Send(msg string) error
+	Send(ctx context.Context, msg string) error
 }
 
 type Formatter interface {
@@ -107,4 +250,6 @@ type Formatter interface {
 		maxMsgLen int,
 	) ([]string, error)
 	FilterGradesChanges(courseChanges []formatter.CourseGradesChange) []formatter.CourseGradesChange
+	ConvertOverviewToString(courses []formatter.Course, maxMsgLen int) []string
+	ConvertWarningsToString(warnings []formatter.Warning, maxMsgLen int) []string
 }
@@ -0,0 +1,105 @@
+package notifyer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/formatter"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/telegram"
+)
+
+// fakeService fails the messages that contain failOn with err.
+type fakeService struct {
+	messages []string
+	failOn   string
+	err      error
+}
+
+func (s *fakeService) Send(ctx context.Context, msg string) error {
+	if s.err != nil && strings.Contains(msg, s.failOn) {
+		return s.err
+	}
+	s.messages = append(s.messages, msg)
+
+	return nil
+}
+
+func TestSendUpdates(t *testing.T) {
+	f := formatter.NewFormatter(formatter.FormatConfig{
+		ToPrint:          []string{"Title"},
+		ToPrintOnUpdates: []string{"Grade"},
+		UpdatesToCheck:   []string{"Grade"},
+	})
+
+	change := func(courseName string) formatter.CourseGradesChange {
+		return formatter.CourseGradesChange{
+			Course: formatter.Course{Fullname: courseName},
+			GradesTableChange: []formatter.GradeRowChange{{
+				Type:   "update",
+				Fields: []string{"Grade"},
+				From:   formatter.GradeReport{Title: "Lab 4", Grade: "8"},
+				To:     formatter.GradeReport{Title: "Lab 4", Grade: "9"},
+			}},
+			TotalChange: formatter.CourseTotalChange{Type: "nochange"},
+		}
+	}
+	updates := []formatter.CourseGradesChange{change("AGLA"), change("DSA"), change("SSAD")}
+
+	t.Run("delivered messages are not sent again", func(t *testing.T) {
+		service := &fakeService{failOn: "DSA", err: errors.New("telegram is down")}
+		n := NewNotifyer(service, "fake", f, "", logging.Discard())
+
+		sent, err := n.SendUpdates(context.Background(), updates)
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, sent)
+
+		service.err = nil
+
+		sent, err = n.SendUpdates(context.Background(), updates)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, sent)
+		assert.Len(t, service.messages, 3)
+
+		sent, err = n.SendUpdates(context.Background(), updates)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, sent, "updates are sent again once all of them are delivered")
+	})
+
+	t.Run("rejected message is skipped", func(t *testing.T) {
+		service := &fakeService{failOn: "DSA", err: fmt.Errorf("%w: Bad Request", telegram.ErrRejected)}
+		n := NewNotifyer(service, "fake", f, "", logging.Discard())
+
+		sent, err := n.SendUpdates(context.Background(), updates)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, sent)
+		assert.Contains(t, service.messages[1], "SSAD")
+	})
+
+	t.Run("delivered messages are kept over a reload", func(t *testing.T) {
+		service := &fakeService{failOn: "DSA", err: errors.New("telegram is down")}
+		n := NewNotifyer(service, "fake", f, "", logging.Discard())
+
+		_, err := n.SendUpdates(context.Background(), updates)
+		assert.Error(t, err)
+
+		service.err = nil
+		reloaded := NewNotifyer(service, "fake", f, "", logging.Discard())
+		reloaded.KeepSent(&n)
+
+		sent, err := reloaded.SendUpdates(context.Background(), updates)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, sent)
+		assert.Len(t, service.messages, 3)
+	})
+}
@@ -2,24 +2,60 @@ package telegram
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 
 	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/telegram/telegram"
 )
 
+// ErrRejected is a message telegram answered with a client error, e.g. a
+// malformed or too long one, sending it again fails the same way.
+var ErrRejected = errors.New("message rejected")
+
+// htmlEscaper escapes the text telegram parses as HTML, the messages carry
+// no markup of their own.
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
 type TelegramService struct {
 	tg *telegram.Telegram
 }
 
-func NewTelegramService(botid string, chatid int) TelegramService {
-	tel, _ := telegram.New(botid)
+func NewTelegramService(botid string, chatid int) (TelegramService, error) {
+	tel, err := telegram.New(botid)
+	if err != nil {
+		return TelegramService{}, fmt.Errorf("failed to connect to telegram: %v", err)
+	}
 	tel.AddReceivers(int64(chatid))
 
-	return TelegramService{tel}
+	return TelegramService{tel}, nil
 }
 
-func (tn TelegramService) Send(msg string) error {
-	ctx := context.Background()
-	err := tn.tg.Send(ctx, msg)
+func (tn TelegramService) Send(ctx context.Context, msg string) error {
+	err := tn.tg.Send(ctx, escapeHTML(msg))
+	if isRejected(err) {
+		return fmt.Errorf("%w: %v", ErrRejected, err)
+	}
 
 	return err
 }
+
+// isRejected reports whether telegram refused the message itself. Too many
+// requests is an error of the api as well, but the message is accepted
+// after the wait.
+func isRejected(err error) bool {
+	var apiErr tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	return apiErr.RetryAfter == 0 && !strings.HasPrefix(apiErr.Message, "Too Many Requests")
+}
+
+// escapeHTML keeps "&", "<" and ">" of grades and titles from being taken
+// for markup, telegram rejects the message otherwise.
+func escapeHTML(msg string) string {
+	return htmlEscaper.Replace(msg)
+}
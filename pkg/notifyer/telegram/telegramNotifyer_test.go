@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"errors"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/formatter"
+)
+
+func TestEscapeHTML(t *testing.T) {
+	f := formatter.NewFormatter(formatter.FormatConfig{
+		ToPrint:          []string{"Title"},
+		ToPrintOnUpdates: []string{"Feedback"},
+		UpdatesToCheck:   []string{"Feedback"},
+	})
+
+	// moodle sends "Q&amp;A &lt;draft&gt;" and "a &lt; b", the cells are
+	// decoded when the grade table is parsed
+	changes := []formatter.CourseGradesChange{{
+		Course: formatter.Course{Fullname: "R&D"},
+		GradesTableChange: []formatter.GradeRowChange{{
+			Type:   "update",
+			Fields: []string{"Feedback"},
+			From:   formatter.GradeReport{Title: "Q&A <draft>", Feedback: ""},
+			To:     formatter.GradeReport{Title: "Q&A <draft>", Feedback: "a < b"},
+		}},
+		TotalChange: formatter.CourseTotalChange{Type: "nochange"},
+	}}
+
+	messages, err := f.ConvertUpdatesToString(changes, 4096)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Contains(t, messages[0], `Q&A <draft>`, "the formatter output is plain text")
+
+	msg := escapeHTML(messages[0])
+
+	assert.Contains(t, msg, "R&amp;D:")
+	assert.Contains(t, msg, `Title:  "Q&amp;A &lt;draft&gt;"`)
+	assert.Contains(t, msg, `Feedback:  ""  -&gt;  "a &lt; b"`)
+	assert.NotContains(t, msg, "<")
+}
+
+func TestIsRejected(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"no error", nil, false},
+		{"network error", errors.New("connection reset by peer"), false},
+		{"bad request", tgbotapi.Error{Message: "Bad Request: can't parse entities"}, true},
+		{"forbidden", tgbotapi.Error{Message: "Forbidden: bot was blocked by the user"}, true},
+		{
+			"too many requests",
+			tgbotapi.Error{Message: "Too Many Requests: retry after 5", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5}},
+			false,
+		},
+		{"wrapped", pkgerrors.Wrapf(tgbotapi.Error{Message: "Bad Request: message is too long"}, "failed to send message"), true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, isRejected(c.err))
+		})
+	}
+}
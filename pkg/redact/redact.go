@@ -0,0 +1,114 @@
+package redact
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+const Mask = "[REDACTED]"
+
+// minSecretLength keeps short values, like an empty or a one letter
+// password, from masking every match of them.
+const minSecretLength = 4
+
+type pattern struct {
+	regexp      *regexp.Regexp
+	replacement string
+}
+
+// patterns find secrets by how they look, so the ones that are not known,
+// like a token that was just got, are masked too.
+var patterns = []pattern{
+	// query strings and forms, e.g. wstoken=... or moodlemobile://token=...
+	{
+		regexp.MustCompile(`(?i)\b(wstoken|token|privatetoken|password|passwd|sesskey|code|moodlesession\w*)=[^&\s"'<>;]+`),
+		"${1}=" + Mask,
+	},
+	// JSON values, e.g. the credentials file or M.cfg of moodle pages
+	{
+		regexp.MustCompile(`(?i)"(wstoken|token|privatetoken|password|sesskey|telegramBotKey)"(\s*):(\s*)"[^"]*"`),
+		`"${1}"${2}:${3}"` + Mask + `"`,
+	},
+	// hidden inputs of HTML forms, e.g. the SSO code
+	{
+		regexp.MustCompile(`(?i)(name="(?:code|sesskey|password|token|logintoken|id_token)"[^>]*?\bvalue=")[^"]*`),
+		"${1}" + Mask,
+	},
+	// cookie and authorization headers
+	{
+		regexp.MustCompile(`(?im)^((?:set-)?cookie:[ \t]*).*$`),
+		"${1}" + Mask,
+	},
+	{
+		regexp.MustCompile(`(?i)(authorization:[ \t]*\w+[ \t]+)\S+`),
+		"${1}" + Mask,
+	},
+	// telegram bot API URLs hold the bot key
+	{
+		regexp.MustCompile(`\bbot\d+:[\w-]+`),
+		"bot" + Mask,
+	},
+}
+
+// Redactor masks secrets in logs and debug dumps: the values it was made
+// with and anything that looks like a token, a password, a session key or
+// a cookie.
+type Redactor struct {
+	secrets []string
+}
+
+// New makes a Redactor of the known secrets, empty ones are skipped.
+func New(secrets ...string) Redactor {
+	known := []string{}
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			known = append(known, secret)
+		}
+	}
+
+	return Redactor{known}
+}
+
+// String masks the secrets of s with the patterns only, e.g. when the
+// config is not known.
+func String(s string) string {
+	return Redactor{}.String(s)
+}
+
+func (r Redactor) String(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+	for _, p := range patterns {
+		s = p.regexp.ReplaceAllString(s, p.replacement)
+	}
+
+	return s
+}
+
+func (r Redactor) Bytes(b []byte) []byte {
+	return []byte(r.String(string(b)))
+}
+
+// Writer masks every write to w. A log.Logger writes an entry at once, so
+// a secret is never split between writes.
+func (r Redactor) Writer(w io.Writer) io.Writer {
+	return writer{r, w}
+}
+
+type writer struct {
+	redactor Redactor
+	w        io.Writer
+}
+
+// Write reports len(p) on success, the redacted data may be longer or
+// shorter than p.
+func (w writer) Write(p []byte) (int, error) {
+	_, err := w.w.Write(w.redactor.Bytes(p))
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,89 @@
+package redact
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			"moodle URL",
+			`Post "https://moodle.example/webservice/rest/server.php?wsfunction=x&wstoken=abc123": EOF`,
+			`Post "https://moodle.example/webservice/rest/server.php?wsfunction=x&wstoken=[REDACTED]": EOF`,
+		},
+		{
+			"mobile app launch",
+			`Get "moodlemobile://token=YWJjOjo6ZGVm": unsupported protocol scheme`,
+			`Get "moodlemobile://token=[REDACTED]": unsupported protocol scheme`,
+		},
+		{
+			"form",
+			`UserName=user&Password=hunter2&Kmsi=true`,
+			`UserName=user&Password=[REDACTED]&Kmsi=true`,
+		},
+		{
+			"JSON",
+			`{"login":"user","password":"hunter2","token": "abc"}`,
+			`{"login":"user","password":"[REDACTED]","token": "[REDACTED]"}`,
+		},
+		{
+			"moodle page config",
+			`M.cfg = {"wwwroot":"https://moodle.example","sesskey":"Xy12"};`,
+			`M.cfg = {"wwwroot":"https://moodle.example","sesskey":"[REDACTED]"};`,
+		},
+		{
+			"hidden input",
+			`<input type="hidden" name="code" value="secret-code" />`,
+			`<input type="hidden" name="code" value="[REDACTED]" />`,
+		},
+		{
+			"cookies",
+			"Set-Cookie: MoodleSession=abc; path=/\nCookie: MoodleSession=abc",
+			"Set-Cookie: [REDACTED]\nCookie: [REDACTED]",
+		},
+		{
+			"session cookie",
+			`MoodleSessionprod=abc; path=/`,
+			`MoodleSessionprod=[REDACTED]; path=/`,
+		},
+		{
+			"telegram bot URL",
+			`Post "https://api.telegram.org/bot123456:AA-bb_CC/sendMessage": timeout`,
+			`Post "https://api.telegram.org/bot[REDACTED]/sendMessage": timeout`,
+		},
+		{
+			"error codes are kept",
+			`moodle core_webservice_get_site_info failed: Invalid token (invalidtoken), errorcode=invalidtoken`,
+			`moodle core_webservice_get_site_info failed: Invalid token (invalidtoken), errorcode=invalidtoken`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, String(c.text))
+		})
+	}
+
+	t.Run("known secrets", func(t *testing.T) {
+		redactor := New("hunter2", "", "a")
+
+		assert.Equal(t, "login failed for a with [REDACTED]", redactor.String("login failed for a with hunter2"))
+	})
+
+	t.Run("logger", func(t *testing.T) {
+		out := bytes.Buffer{}
+		logger := log.New(New("hunter2").Writer(&out), "", 0)
+
+		logger.Printf("password is %s", "hunter2")
+
+		assert.Equal(t, "password is [REDACTED]\n", out.String())
+	})
+}
@@ -0,0 +1,222 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+type Format string
+
+const (
+	FormatTable    Format = "table"
+	FormatJSON     Format = "json"
+	FormatCSV      Format = "csv"
+	FormatMarkdown Format = "markdown"
+)
+
+func ParseFormat(format string) (Format, error) {
+	switch Format(format) {
+	case FormatTable, FormatJSON, FormatCSV, FormatMarkdown:
+		return Format(format), nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected table, json, csv or markdown", format)
+	}
+}
+
+// Row is a grade report row with the item titled after its categories. Total
+// rows are titled by moodle, e.g. "Course total", so only Type tells them.
+type Row struct {
+	Item         string `json:"item"`
+	Type         string `json:"type"`
+	Grade        string `json:"grade"`
+	Range        string `json:"range"`
+	Percentage   string `json:"percentage"`
+	Weight       string `json:"weight"`
+	Contribution string `json:"contribution"`
+	Feedback     string `json:"feedback"`
+}
+
+type courseReport struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Total  string `json:"total"`
+	Grades []Row  `json:"grades"`
+}
+
+var columns = []string{"Item", "Grade", "Range", "Percentage", "Weight", "Contribution", "Feedback"}
+
+// FilterCourses keeps the courses with the given ID or with the name matching
+// query as a case insensitive regular expression.
+func FilterCourses(courses []moodle.Course, query string) ([]moodle.Course, error) {
+	if query == "" {
+		return courses, nil
+	}
+
+	id, err := strconv.Atoi(query)
+	isID := err == nil
+
+	pattern, err := regexp.Compile("(?i)" + query)
+	if err != nil {
+		return nil, fmt.Errorf("bad course filter %q: %v", query, err)
+	}
+
+	filteredCourses := []moodle.Course{}
+	for _, course := range courses {
+		if (isID && course.ID == id) || pattern.MatchString(course.Fullname) {
+			filteredCourses = append(filteredCourses, course)
+		}
+	}
+
+	return filteredCourses, nil
+}
+
+func Rows(course moodle.Course) []Row {
+	rows := make([]Row, 0, len(course.Grades))
+	for _, grade := range course.Grades {
+		rows = append(rows, Row{
+			Item:         strings.Join(append(course.GradePath(grade), grade.Title), " › "),
+			Type:         grade.Type,
+			Grade:        grade.Grade,
+			Range:        grade.Range,
+			Percentage:   grade.Persentage,
+			Weight:       grade.Weight,
+			Contribution: grade.Contribution,
+			Feedback:     grade.Feedback,
+		})
+	}
+
+	return rows
+}
+
+func Write(out io.Writer, courses []moodle.Course, format Format) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(out, courses)
+	case FormatCSV:
+		return writeCSV(out, courses)
+	case FormatMarkdown:
+		return writeMarkdown(out, courses)
+	default:
+		return writeTable(out, courses)
+	}
+}
+
+func (row Row) values() []string {
+	return []string{row.Item, row.Grade, row.Range, row.Percentage, row.Weight, row.Contribution, row.Feedback}
+}
+
+func writeTable(out io.Writer, courses []moodle.Course) error {
+	for i, course := range courses {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s (total %s)\n", course.Fullname, courseTotal(course))
+
+		table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, strings.Join(columns, "\t"))
+		for _, row := range Rows(course) {
+			fmt.Fprintln(table, strings.Join(singleLine(row.values()), "\t"))
+		}
+
+		err := table.Flush()
+		if err != nil {
+			return fmt.Errorf("failed to write grades table: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func writeJSON(out io.Writer, courses []moodle.Course) error {
+	reports := make([]courseReport, 0, len(courses))
+	for _, course := range courses {
+		reports = append(reports, courseReport{
+			ID:     course.ID,
+			Name:   course.Fullname,
+			Total:  course.Total.Grade,
+			Grades: Rows(course),
+		})
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(reports)
+	if err != nil {
+		return fmt.Errorf("failed to write grades json: %v", err)
+	}
+
+	return nil
+}
+
+func writeCSV(out io.Writer, courses []moodle.Course) error {
+	writer := csv.NewWriter(out)
+
+	writer.Write(append([]string{"Course ID", "Course", "Type"}, columns...))
+	for _, course := range courses {
+		for _, row := range Rows(course) {
+			writer.Write(append([]string{strconv.Itoa(course.ID), course.Fullname, row.Type}, row.values()...))
+		}
+	}
+
+	writer.Flush()
+	err := writer.Error()
+	if err != nil {
+		return fmt.Errorf("failed to write grades csv: %v", err)
+	}
+
+	return nil
+}
+
+func writeMarkdown(out io.Writer, courses []moodle.Course) error {
+	for i, course := range courses {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "## %s\n\nTotal: %s\n\n", escapeMarkdown(course.Fullname), courseTotal(course))
+
+		fmt.Fprintf(out, "| %s |\n", strings.Join(columns, " | "))
+		fmt.Fprintf(out, "|%s\n", strings.Repeat(" --- |", len(columns)))
+		for _, row := range Rows(course) {
+			values := singleLine(row.values())
+			for i := range values {
+				values[i] = escapeMarkdown(values[i])
+			}
+
+			_, err := fmt.Fprintf(out, "| %s |\n", strings.Join(values, " | "))
+			if err != nil {
+				return fmt.Errorf("failed to write grades markdown: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func courseTotal(course moodle.Course) string {
+	if course.Total.Grade == "" {
+		return "-"
+	}
+
+	return course.Total.Grade
+}
+
+func singleLine(values []string) []string {
+	for i, value := range values {
+		values[i] = strings.Join(strings.Fields(value), " ")
+	}
+
+	return values
+}
+
+func escapeMarkdown(text string) string {
+	return strings.ReplaceAll(text, "|", "\\|")
+}
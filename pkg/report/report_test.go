@@ -0,0 +1,71 @@
+package report
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+func TestWrite(t *testing.T) {
+	courses := []moodle.Course{{
+		ID:       42,
+		Fullname: "AGLA | II",
+		Grades: []moodle.GradeReport{
+			{ID: 1, Title: "Lab 1", Grade: "8.00", Range: "0–10", Feedback: "Good\n\njob", ParentID: 8, Type: moodle.GradeRowItem},
+			{ID: 2, Title: "Course total", Grade: "8.00", ParentID: 7, Type: moodle.GradeRowCourseTotal},
+		},
+		Categories: []moodle.GradeCategory{{ID: 7, Title: "AGLA"}, {ID: 8, ParentID: 7, Title: "Labs"}},
+		Total:      moodle.CourseTotal{Grade: "3.20"},
+	}}
+
+	t.Run("csv", func(t *testing.T) {
+		out := bytes.Buffer{}
+
+		err := Write(&out, courses, FormatCSV)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Course ID,Course,Type,Item,Grade,Range,Percentage,Weight,Contribution,Feedback\n"+
+			"42,AGLA | II,item,Labs › Lab 1,8.00,0–10,,,,\"Good\n\njob\"\n"+
+			"42,AGLA | II,coursetotal,Course total,8.00,,,,,\n", out.String())
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		out := bytes.Buffer{}
+
+		err := Write(&out, courses, FormatMarkdown)
+
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), "## AGLA \\| II\n")
+		assert.Contains(t, out.String(), "| Labs › Lab 1 | 8.00 | 0–10 |  |  |  | Good job |\n")
+	})
+
+	t.Run("table", func(t *testing.T) {
+		out := bytes.Buffer{}
+
+		err := Write(&out, courses, FormatTable)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "AGLA | II (total 3.20)\n"+
+			"Item          Grade  Range  Percentage  Weight  Contribution  Feedback\n"+
+			"Labs › Lab 1  8.00   0–10                                     Good job\n"+
+			"Course total  8.00                                            \n", out.String())
+	})
+}
+
+func TestFilterCourses(t *testing.T) {
+	courses := []moodle.Course{{ID: 42, Fullname: "[S23] AGLA II"}, {ID: 43, Fullname: "Physics"}}
+
+	byID, err := FilterCourses(courses, "43")
+	assert.NoError(t, err)
+	assert.Equal(t, courses[1:], byID)
+
+	byName, err := FilterCourses(courses, "agla")
+	assert.NoError(t, err)
+	assert.Equal(t, courses[:1], byName)
+
+	_, err = FilterCourses(courses, "(")
+	assert.Error(t, err)
+}
@@ -0,0 +1,208 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSchedule is a standard five field cron expression: minute, hour, day
+// of month, month and day of week. Every field is a bitset of the allowed
+// values.
+type cronSchedule struct {
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+
+	isDayOfMonthStar bool
+	isDayOfWeekStar  bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteField     = cronField{"minute", 0, 59}
+	hourField       = cronField{"hour", 0, 23}
+	dayOfMonthField = cronField{"day of month", 1, 31}
+	monthField      = cronField{"month", 1, 12}
+	dayOfWeekField  = cronField{"day of week", 0, 7}
+)
+
+func parseCron(expr string) (cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("bad cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	schedule := cronSchedule{
+		isDayOfMonthStar: fields[2] == "*",
+		isDayOfWeekStar:  fields[4] == "*",
+	}
+
+	var err error
+	bitsets := []*uint64{
+		&schedule.minute,
+		&schedule.hour,
+		&schedule.dayOfMonth,
+		&schedule.month,
+		&schedule.dayOfWeek,
+	}
+	cronFields := []cronField{minuteField, hourField, dayOfMonthField, monthField, dayOfWeekField}
+	for i, field := range fields {
+		*bitsets[i], err = cronFields[i].parse(field)
+		if err != nil {
+			return cronSchedule{}, fmt.Errorf("bad cron expression %q: %v", expr, err)
+		}
+	}
+
+	// both 0 and 7 stand for sunday
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek |= 1
+	}
+
+	return schedule, nil
+}
+
+// parse reads a comma separated list of "*", "a", "a-b" with an optional
+// "/step".
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		valueRange, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad %s step %q", f.name, stepStr)
+			}
+		}
+
+		from, to, err := f.parseRange(valueRange, hasStep)
+		if err != nil {
+			return 0, err
+		}
+
+		for value := from; value <= to; value += step {
+			bits |= 1 << value
+		}
+	}
+
+	return bits, nil
+}
+
+func (f cronField) parseRange(valueRange string, hasStep bool) (int, int, error) {
+	if valueRange == "*" {
+		return f.min, f.max, nil
+	}
+
+	fromStr, toStr, isRange := strings.Cut(valueRange, "-")
+
+	from, err := f.parseValue(fromStr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if !isRange {
+		// "5/10" means "from 5 to the end every 10"
+		if hasStep {
+			return from, f.max, nil
+		}
+		return from, from, nil
+	}
+
+	to, err := f.parseValue(toStr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if from > to {
+		return 0, 0, fmt.Errorf("bad %s range %q", f.name, valueRange)
+	}
+
+	return from, to, nil
+}
+
+func (f cronField) parseValue(valueStr string) (int, error) {
+	value, err := strconv.Atoi(valueStr)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("bad %s %q, expected %d-%d", f.name, valueStr, f.min, f.max)
+	}
+
+	return value, nil
+}
+
+// next returns the first time after t that matches the schedule in the
+// location of t, or the zero time if there is none in the next five years.
+func (s cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	yearLimit := t.Year() + 5
+
+	for t.Year() <= yearLimit {
+		if !hasBit(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !hasBit(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if !hasBit(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// matchesDay follows cron: if both day fields are restricted, a day matching
+// either of them is enough.
+func (s cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonthMatches := hasBit(s.dayOfMonth, t.Day())
+	dayOfWeekMatches := hasBit(s.dayOfWeek, int(t.Weekday()))
+
+	if s.isDayOfMonthStar || s.isDayOfWeekStar {
+		return dayOfMonthMatches && dayOfWeekMatches
+	}
+
+	return dayOfMonthMatches || dayOfWeekMatches
+}
+
+func hasBit(bits uint64, value int) bool {
+	return bits&(1<<value) != 0
+}
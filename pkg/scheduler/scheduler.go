@@ -0,0 +1,232 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Config configures when checks run: on Schedules, or every CheckInterval
+// without them, in TimeZone (the local one if empty). Changes found between
+// QuietHoursStart and QuietHoursEnd ("15:04") are sent as one digest after.
+type Config struct {
+	Schedules       []string
+	CheckInterval   time.Duration
+	QuietHoursStart string
+	QuietHoursEnd   string
+	TimeZone        string
+}
+
+type Scheduler struct {
+	cfg           Config
+	schedules     []cronSchedule
+	checkInterval time.Duration
+	quietHours    quietHours
+	location      *time.Location
+	log           *slog.Logger
+}
+
+// quietHours holds minutes since midnight, the period wraps around midnight
+// if start is after end.
+type quietHours struct {
+	isSet      bool
+	start, end int
+}
+
+func NewScheduler(cfg Config, log *slog.Logger) (Scheduler, error) {
+	location := time.Local
+	if cfg.TimeZone != "" {
+		var err error
+		location, err = time.LoadLocation(cfg.TimeZone)
+		if err != nil {
+			return Scheduler{}, fmt.Errorf("failed to create scheduler: bad time zone: %v", err)
+		}
+	}
+
+	schedules := []cronSchedule{}
+	for _, expr := range cfg.Schedules {
+		schedule, err := parseCron(expr)
+		if err != nil {
+			return Scheduler{}, fmt.Errorf("failed to create scheduler: %v", err)
+		}
+		if schedule.next(time.Now().In(location)).IsZero() {
+			return Scheduler{}, fmt.Errorf("failed to create scheduler: cron expression %q never matches", expr)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if len(schedules) == 0 && cfg.CheckInterval <= 0 {
+		return Scheduler{}, fmt.Errorf("failed to create scheduler: no schedules and no check interval")
+	}
+
+	quiet, err := parseQuietHours(cfg.QuietHoursStart, cfg.QuietHoursEnd)
+	if err != nil {
+		return Scheduler{}, fmt.Errorf("failed to create scheduler: %v", err)
+	}
+
+	scheduler := Scheduler{
+		cfg:           cfg,
+		schedules:     schedules,
+		checkInterval: cfg.CheckInterval,
+		quietHours:    quiet,
+		location:      location,
+		log:           log,
+	}
+
+	return scheduler, nil
+}
+
+// NextCheck returns the time of the check after now. The end of quiet hours
+// is always a check, so the digest is not delayed until the next schedule.
+func (s Scheduler) NextCheck(now time.Time) time.Time {
+	now = now.In(s.location)
+
+	next := now.Add(s.checkInterval)
+	if len(s.schedules) > 0 {
+		next = time.Time{}
+		for _, schedule := range s.schedules {
+			scheduleNext := schedule.next(now)
+			if next.IsZero() || (!scheduleNext.IsZero() && scheduleNext.Before(next)) {
+				next = scheduleNext
+			}
+		}
+	}
+
+	if s.quietHours.isSet {
+		quietEnd := s.quietHours.nextEnd(now)
+		if next.IsZero() || quietEnd.Before(next) {
+			next = quietEnd
+		}
+	}
+
+	return next
+}
+
+// NextRetry returns the time to repeat a failed check after delay.
+// Scheduled checks before it are skipped, otherwise a frequent schedule
+// would keep polling a moodle that is down and the backoff would never
+// take effect, the delay is bounded by the backoff maximum instead.
+func (s Scheduler) NextRetry(now time.Time, delay time.Duration) time.Time {
+	return now.In(s.location).Add(delay)
+}
+
+// IsQuiet reports whether t is within quiet hours.
+func (s Scheduler) IsQuiet(t time.Time) bool {
+	return s.quietHours.contains(t.In(s.location))
+}
+
+// Equal reports whether s and other are made from the same config.
+func (s Scheduler) Equal(other Scheduler) bool {
+	return slices.Equal(s.cfg.Schedules, other.cfg.Schedules) &&
+		s.cfg.CheckInterval == other.cfg.CheckInterval &&
+		s.cfg.QuietHoursStart == other.cfg.QuietHoursStart &&
+		s.cfg.QuietHoursEnd == other.cfg.QuietHoursEnd &&
+		s.cfg.TimeZone == other.cfg.TimeZone
+}
+
+// WaitUntil returns the context error if ctx is done before nextCheck.
+func (s Scheduler) WaitUntil(ctx context.Context, nextCheck time.Time) error {
+	s.log.InfoContext(ctx, "waiting for the next check", "next_check", nextCheck.Format(time.RFC3339))
+
+	timer := time.NewTimer(time.Until(nextCheck))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Validate returns every problem of the config, NewScheduler stops at the
+// first one.
+func (cfg Config) Validate() []error {
+	problems := []error{}
+
+	location, err := time.LoadLocation(cfg.TimeZone)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("bad time zone: %v", err))
+		location = time.Local
+	}
+
+	for _, expr := range cfg.Schedules {
+		schedule, err := parseCron(expr)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if schedule.next(time.Now().In(location)).IsZero() {
+			problems = append(problems, fmt.Errorf("cron expression %q never matches", expr))
+		}
+	}
+
+	if len(cfg.Schedules) == 0 && cfg.CheckInterval <= 0 {
+		problems = append(problems, fmt.Errorf("no schedules and no check interval"))
+	}
+
+	_, err = parseQuietHours(cfg.QuietHoursStart, cfg.QuietHoursEnd)
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	return problems
+}
+
+func parseQuietHours(start, end string) (quietHours, error) {
+	if start == "" && end == "" {
+		return quietHours{}, nil
+	}
+
+	startMinute, err := parseClock(start)
+	if err != nil {
+		return quietHours{}, fmt.Errorf("bad quiet hours start: %v", err)
+	}
+
+	endMinute, err := parseClock(end)
+	if err != nil {
+		return quietHours{}, fmt.Errorf("bad quiet hours end: %v", err)
+	}
+
+	quiet := quietHours{
+		isSet: startMinute != endMinute,
+		start: startMinute,
+		end:   endMinute,
+	}
+
+	return quiet, nil
+}
+
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (q quietHours) contains(t time.Time) bool {
+	if !q.isSet {
+		return false
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	if q.start < q.end {
+		return q.start <= minute && minute < q.end
+	}
+
+	return minute >= q.start || minute < q.end
+}
+
+func (q quietHours) nextEnd(now time.Time) time.Time {
+	end := time.Date(now.Year(), now.Month(), now.Day(), q.end/60, q.end%60, 0, 0, now.Location())
+	if !end.After(now) {
+		end = time.Date(now.Year(), now.Month(), now.Day()+1, q.end/60, q.end%60, 0, 0, now.Location())
+	}
+
+	return end
+}
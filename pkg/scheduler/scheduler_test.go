@@ -0,0 +1,166 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+func TestCronSchedule(t *testing.T) {
+	// 2023-05-19 is a friday
+	now := time.Date(2023, time.May, 19, 22, 50, 30, 0, time.UTC)
+
+	cases := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"*/15 * * * *", time.Date(2023, time.May, 19, 23, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2023, time.May, 19, 23, 0, 0, 0, time.UTC)},
+		{"50 22 * * *", time.Date(2023, time.May, 20, 22, 50, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2023, time.May, 22, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2023, time.May, 21, 9, 0, 0, 0, time.UTC)},
+		{"5/20 8-10,23 * * *", time.Date(2023, time.May, 19, 23, 5, 0, 0, time.UTC)},
+		{"0 0 1 6 *", time.Date(2023, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1 * 1", time.Date(2023, time.May, 22, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expr, func(t *testing.T) {
+			schedule, err := parseCron(c.expr)
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, schedule.next(now))
+		})
+	}
+
+	t.Run("bad expressions", func(t *testing.T) {
+		for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+			_, err := parseCron(expr)
+			assert.Error(t, err, expr)
+		}
+	})
+
+	t.Run("never matching expression", func(t *testing.T) {
+		schedule, err := parseCron("0 0 30 2 *")
+
+		assert.NoError(t, err)
+		assert.True(t, schedule.next(now).IsZero())
+	})
+}
+
+func TestScheduler(t *testing.T) {
+	logger := logging.Discard()
+
+	t.Run("check interval without schedules", func(t *testing.T) {
+		s, err := NewScheduler(Config{CheckInterval: time.Hour, TimeZone: "UTC"}, logger)
+		assert.NoError(t, err)
+
+		now := time.Date(2023, time.May, 19, 12, 0, 0, 0, time.UTC)
+		assert.Equal(t, now.Add(time.Hour), s.NextCheck(now))
+	})
+
+	t.Run("earliest of the schedules", func(t *testing.T) {
+		s, err := NewScheduler(Config{Schedules: []string{"0 * * * *", "*/10 9-17 * * *"}, TimeZone: "UTC"}, logger)
+		assert.NoError(t, err)
+
+		assert.Equal(
+			t,
+			time.Date(2023, time.May, 19, 12, 10, 0, 0, time.UTC),
+			s.NextCheck(time.Date(2023, time.May, 19, 12, 1, 0, 0, time.UTC)),
+		)
+		assert.Equal(
+			t,
+			time.Date(2023, time.May, 19, 19, 0, 0, 0, time.UTC),
+			s.NextCheck(time.Date(2023, time.May, 19, 18, 1, 0, 0, time.UTC)),
+		)
+	})
+
+	t.Run("time zone", func(t *testing.T) {
+		s, err := NewScheduler(Config{Schedules: []string{"0 9 * * *"}, TimeZone: "Europe/Moscow"}, logger)
+		assert.NoError(t, err)
+
+		next := s.NextCheck(time.Date(2023, time.May, 19, 5, 0, 0, 0, time.UTC))
+		assert.Equal(t, time.Date(2023, time.May, 19, 6, 0, 0, 0, time.UTC), next.UTC())
+	})
+
+	t.Run("empty time zone is local", func(t *testing.T) {
+		local := time.Local
+		defer func() { time.Local = local }()
+		time.Local = time.FixedZone("UTC+3", 3*60*60)
+
+		s, err := NewScheduler(Config{Schedules: []string{"0 9 * * *"}}, logger)
+		assert.NoError(t, err)
+
+		next := s.NextCheck(time.Date(2023, time.May, 19, 5, 0, 0, 0, time.UTC))
+		assert.Equal(t, time.Date(2023, time.May, 19, 6, 0, 0, 0, time.UTC), next.UTC())
+	})
+
+	t.Run("quiet hours", func(t *testing.T) {
+		s, err := NewScheduler(Config{
+			Schedules:       []string{"0 */3 * * *"},
+			QuietHoursStart: "23:00",
+			QuietHoursEnd:   "07:30",
+			TimeZone:        "UTC",
+		}, logger)
+		assert.NoError(t, err)
+
+		assert.True(t, s.IsQuiet(time.Date(2023, time.May, 19, 23, 0, 0, 0, time.UTC)))
+		assert.True(t, s.IsQuiet(time.Date(2023, time.May, 20, 7, 29, 0, 0, time.UTC)))
+		assert.False(t, s.IsQuiet(time.Date(2023, time.May, 20, 7, 30, 0, 0, time.UTC)))
+		assert.False(t, s.IsQuiet(time.Date(2023, time.May, 19, 12, 0, 0, 0, time.UTC)))
+
+		assert.Equal(
+			t,
+			time.Date(2023, time.May, 20, 7, 30, 0, 0, time.UTC),
+			s.NextCheck(time.Date(2023, time.May, 20, 6, 1, 0, 0, time.UTC)),
+			"the digest is sent when quiet hours end",
+		)
+	})
+
+	t.Run("retry skips scheduled checks", func(t *testing.T) {
+		s, err := NewScheduler(Config{Schedules: []string{"*/5 * * * *"}, TimeZone: "UTC"}, logger)
+		assert.NoError(t, err)
+
+		now := time.Date(2023, time.May, 19, 12, 1, 0, 0, time.UTC)
+		assert.Equal(t, time.Date(2023, time.May, 19, 12, 2, 0, 0, time.UTC), s.NextRetry(now, time.Minute))
+		assert.Equal(t, time.Date(2023, time.May, 19, 13, 1, 0, 0, time.UTC), s.NextRetry(now, time.Hour))
+	})
+
+	t.Run("bad config", func(t *testing.T) {
+		_, err := NewScheduler(Config{CheckInterval: time.Hour, TimeZone: "Mars/Olympus"}, logger)
+		assert.Error(t, err)
+
+		_, err = NewScheduler(Config{CheckInterval: time.Hour, QuietHoursStart: "25:00", QuietHoursEnd: "07:00"}, logger)
+		assert.Error(t, err)
+
+		_, err = NewScheduler(Config{}, logger)
+		assert.Error(t, err)
+	})
+}
+
+func TestSchedulerEqual(t *testing.T) {
+	logger := logging.Discard()
+	cfg := Config{Schedules: []string{"@hourly"}, QuietHoursStart: "23:00", QuietHoursEnd: "07:00", TimeZone: "UTC"}
+
+	s, err := NewScheduler(cfg, logger)
+	assert.NoError(t, err)
+
+	same, err := NewScheduler(Config{Schedules: []string{"@hourly"}, QuietHoursStart: "23:00", QuietHoursEnd: "07:00", TimeZone: "UTC"}, logger)
+	assert.NoError(t, err)
+	assert.True(t, s.Equal(same))
+
+	cfg.Schedules = []string{"@daily"}
+	changed, err := NewScheduler(cfg, logger)
+	assert.NoError(t, err)
+	assert.False(t, s.Equal(changed))
+
+	cfg.Schedules = []string{"@hourly"}
+	cfg.TimeZone = "Europe/Moscow"
+	changed, err = NewScheduler(cfg, logger)
+	assert.NoError(t, err)
+	assert.False(t, s.Equal(changed))
+}
@@ -0,0 +1,78 @@
+package secret
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+)
+
+// FileMode is the only mode secret files may have, they hold passwords.
+const FileMode os.FileMode = 0600
+
+// ReadFile reads a file written by WriteFile, it may be in plaintext if it
+// was written before a key was set. A file other users can read is given
+// FileMode with a warning, it is refused if its mode cannot be changed, e.g.
+// it belongs to another user.
+func ReadFile(path string, key Key, log *slog.Logger) ([]byte, error) {
+	err := restrictPermissions(path, log)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsSealed(data) {
+		return data, nil
+	}
+
+	return key.Open(data)
+}
+
+// WriteFile writes data with FileMode, encrypted if the key is set.
+func WriteFile(path string, data []byte, key Key) error {
+	if key.IsSet() {
+		var err error
+		data, err = key.Seal(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := os.WriteFile(path, data, FileMode)
+	if err != nil {
+		return err
+	}
+
+	// the mode is only set for a new file
+	return os.Chmod(path, FileMode)
+}
+
+// restrictPermissions sets FileMode on a file that is readable or writable
+// by the group or others. Windows has no such permissions.
+func restrictPermissions(path string, log *slog.Logger) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	mode := info.Mode().Perm()
+	if mode&^FileMode == 0 {
+		return nil
+	}
+
+	err = os.Chmod(path, FileMode)
+	if err != nil {
+		return fmt.Errorf("%q has permissions %v, other users may read it, run chmod 600 on it: %v", path, mode, err)
+	}
+	log.Warn("secret file was readable by other users, its permissions are set to 600", "path", path, "permissions", mode.String())
+
+	return nil
+}
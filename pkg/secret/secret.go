@@ -0,0 +1,163 @@
+package secret
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	keySize   = 32
+	nonceSize = 24
+	saltSize  = 16
+)
+
+var ErrNoKey = errors.New("the file is encrypted, but no key is set")
+
+// Key encrypts files with NaCl secretbox. It is either a random key or a
+// passphrase, which is stretched with scrypt and a salt kept in the file.
+// The zero Key is not set, files are kept in plaintext.
+type Key struct {
+	raw        *[keySize]byte
+	passphrase string
+}
+
+// ParseKey reads a base64 encoded key, see GenerateKey.
+func ParseKey(encoded string) (Key, error) {
+	keyBytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to decode key: %v", err)
+	}
+	if len(keyBytes) != keySize {
+		return Key{}, fmt.Errorf("key must be %d bytes, got %d", keySize, len(keyBytes))
+	}
+
+	raw := [keySize]byte{}
+	copy(raw[:], keyBytes)
+
+	return Key{raw: &raw}, nil
+}
+
+func NewPassphraseKey(passphrase string) Key {
+	return Key{passphrase: passphrase}
+}
+
+// GenerateKey returns a new random key encoded for ParseKey.
+func GenerateKey() (string, error) {
+	raw := make([]byte, keySize)
+	_, err := io.ReadFull(rand.Reader, raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key: %v", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+func (k Key) IsSet() bool {
+	return k.raw != nil || k.passphrase != ""
+}
+
+// sealedFile is the encrypted file, Secretbox marks it and is the version of
+// the format.
+type sealedFile struct {
+	Secretbox int    `json:"secretbox"`
+	Salt      []byte `json:"salt,omitempty"`
+	Nonce     []byte `json:"nonce"`
+	Box       []byte `json:"box"`
+}
+
+// IsSealed reports whether data was encrypted by Seal.
+func IsSealed(data []byte) bool {
+	sealed := sealedFile{}
+	err := json.Unmarshal(data, &sealed)
+
+	return err == nil && sealed.Secretbox != 0
+}
+
+func (k Key) Seal(data []byte) ([]byte, error) {
+	if !k.IsSet() {
+		return nil, ErrNoKey
+	}
+
+	sealed := sealedFile{Secretbox: 1, Nonce: make([]byte, nonceSize)}
+	if k.raw == nil {
+		sealed.Salt = make([]byte, saltSize)
+		_, err := io.ReadFull(rand.Reader, sealed.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt: %v", err)
+		}
+	}
+	_, err := io.ReadFull(rand.Reader, sealed.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %v", err)
+	}
+
+	key, err := k.derive(sealed.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := [nonceSize]byte{}
+	copy(nonce[:], sealed.Nonce)
+	sealed.Box = secretbox.Seal(nil, data, &nonce, key)
+
+	return json.MarshalIndent(sealed, "", "  ")
+}
+
+func (k Key) Open(data []byte) ([]byte, error) {
+	if !k.IsSet() {
+		return nil, ErrNoKey
+	}
+
+	sealed := sealedFile{}
+	err := json.Unmarshal(data, &sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %v", err)
+	}
+	if sealed.Secretbox != 1 {
+		return nil, fmt.Errorf("failed to decrypt: unknown format version %d", sealed.Secretbox)
+	}
+	if len(sealed.Nonce) != nonceSize {
+		return nil, fmt.Errorf("failed to decrypt: bad nonce")
+	}
+
+	key, err := k.derive(sealed.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := [nonceSize]byte{}
+	copy(nonce[:], sealed.Nonce)
+	opened, ok := secretbox.Open(nil, sealed.Box, &nonce, key)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt: wrong key or damaged file")
+	}
+
+	return opened, nil
+}
+
+// derive returns the raw key or the one made from the passphrase and salt.
+func (k Key) derive(salt []byte) (*[keySize]byte, error) {
+	if k.raw != nil {
+		return k.raw, nil
+	}
+	if len(salt) == 0 {
+		return nil, fmt.Errorf("failed to derive key: the file has no salt for a passphrase")
+	}
+
+	keyBytes, err := scrypt.Key([]byte(k.passphrase), salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %v", err)
+	}
+
+	key := [keySize]byte{}
+	copy(key[:], keyBytes)
+
+	return &key, nil
+}
@@ -0,0 +1,137 @@
+package secret
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+)
+
+func TestSeal(t *testing.T) {
+	encodedKey, err := GenerateKey()
+	assert.NoError(t, err)
+	key, err := ParseKey(encodedKey)
+	assert.NoError(t, err)
+	otherEncodedKey, err := GenerateKey()
+	assert.NoError(t, err)
+	otherKey, err := ParseKey(otherEncodedKey)
+	assert.NoError(t, err)
+
+	data := []byte(`{"password": "hunter2"}`)
+
+	cases := []struct {
+		name      string
+		key       Key
+		openKey   Key
+		isOpened  bool
+		errorText string
+	}{
+		{"key", key, key, true, ""},
+		{"passphrase", NewPassphraseKey("passphrase"), NewPassphraseKey("passphrase"), true, ""},
+		{"wrong key", key, otherKey, false, "wrong key"},
+		{"wrong passphrase", NewPassphraseKey("passphrase"), NewPassphraseKey("other"), false, "wrong key"},
+		{"no key", key, Key{}, false, ErrNoKey.Error()},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sealed, err := c.key.Seal(data)
+			assert.NoError(t, err)
+			assert.True(t, IsSealed(sealed))
+			assert.NotContains(t, string(sealed), "hunter2")
+
+			opened, err := c.openKey.Open(sealed)
+
+			if c.isOpened {
+				assert.NoError(t, err)
+				assert.Equal(t, data, opened)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), c.errorText)
+			}
+		})
+	}
+
+	t.Run("plaintext is not sealed", func(t *testing.T) {
+		assert.False(t, IsSealed(data))
+		assert.False(t, IsSealed([]byte("not json")))
+	})
+
+	t.Run("bad keys", func(t *testing.T) {
+		_, err := ParseKey("not base64!")
+		assert.Error(t, err)
+
+		_, err = ParseKey("c2hvcnQ=")
+		assert.Error(t, err)
+	})
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	key := NewPassphraseKey("passphrase")
+	data := []byte(`{"password": "hunter2"}`)
+
+	t.Run("written encrypted with the file mode", func(t *testing.T) {
+		path := filepath.Join(dir, "encrypted.json")
+		assert.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+
+		err := WriteFile(path, data, key)
+		assert.NoError(t, err)
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, FileMode, info.Mode().Perm())
+
+		stored, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.True(t, IsSealed(stored))
+
+		read, err := ReadFile(path, key, logging.Discard())
+		assert.NoError(t, err)
+		assert.Equal(t, data, read)
+	})
+
+	t.Run("plaintext without a key", func(t *testing.T) {
+		path := filepath.Join(dir, "plaintext.json")
+
+		err := WriteFile(path, data, Key{})
+		assert.NoError(t, err)
+
+		read, err := ReadFile(path, key, logging.Discard())
+		assert.NoError(t, err)
+		assert.Equal(t, data, read)
+	})
+
+	t.Run("readable by others", func(t *testing.T) {
+		path := filepath.Join(dir, "open.json")
+		assert.NoError(t, os.WriteFile(path, data, 0644))
+		assert.NoError(t, os.Chmod(path, 0644))
+		logs := &bytes.Buffer{}
+
+		read, err := ReadFile(path, Key{}, logging.New(logs, logging.FormatText, slog.LevelInfo))
+
+		assert.NoError(t, err)
+		assert.Equal(t, data, read)
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, FileMode, info.Mode().Perm())
+		assert.Contains(t, logs.String(), "permissions are set to 600")
+		assert.Contains(t, logs.String(), "-rw-r--r--")
+	})
+
+	t.Run("readable by others and not owned", func(t *testing.T) {
+		if os.Getuid() == 0 {
+			t.Skip("root may change the mode of any file")
+		}
+
+		_, err := ReadFile("/etc/passwd", Key{}, logging.Discard())
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "chmod 600")
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/formatter"
+)
+
+// replayHistory sends the changes stored in the history again, e.g. after a
+// formatter fix or to a new chat. Each check is sent as it was, filtered and
+// formatted with the current config.
+func replayHistory(args []string) error {
+	flags := newFlagSet("replay")
+	fromFlag := flags.String("from", "", "replay changes since this time")
+	toFlag := flags.String("to", "", "replay changes before this time")
+	service := flags.String("service", "telegram", "service to send to: telegram or stdout")
+	dryRun := flags.Bool("dry-run", false, "print the messages instead of sending them")
+	flags.Parse(args)
+
+	from, err := parseTime(*fromFlag)
+	if err != nil {
+		return err
+	}
+	to, err := parseTime(*toFlag)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(configPath, overrides)
+	if err != nil {
+		return fmt.Errorf("failed to get configuration: %v", err)
+	}
+
+	notifications, err := newReplayNotifyer(&cfg, *service, *dryRun, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	grades := course.NewGrades(course.SaveConfig{
+		LastGradesPath:    cfg.LastGradesPath,
+		GradesHistoryPath: cfg.GradesHistoryPath,
+	}, cfg.Logger)
+
+	history, err := grades.GetHistory(from, to)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	sentMessages, err := sendHistory(ctx, &notifications, history, cfg.Logger)
+	if err != nil {
+		return err
+	}
+
+	cfg.Logger.Info("replayed history", "checks", len(history), "messages", sentMessages)
+
+	return nil
+}
+
+// sendHistory sends the changes of every record and returns the number of
+// messages sent.
+func sendHistory(
+	ctx context.Context,
+	notifications *notifyer.Notifyer,
+	history []course.CourseGradesHistoryField,
+	log *slog.Logger,
+) (int, error) {
+	sentMessages := 0
+	for _, record := range history {
+		if ctx.Err() != nil {
+			return sentMessages, fmt.Errorf("replay interrupted after %d messages", sentMessages)
+		}
+
+		sent, err := notifications.SendUpdates(ctx, formatter.ConvertCourseGradesChange(record.Updates))
+		if err != nil {
+			return sentMessages, fmt.Errorf("failed to replay changes of %v: %v", record.Time.Format(time.RFC3339), err)
+		}
+		sentMessages += sent
+
+		log.Info("replayed changes", "time", record.Time.Format(time.RFC3339), "messages", sent)
+	}
+
+	return sentMessages, nil
+}
+
+// newReplayNotifyer prints the messages to out for a dry run and the stdout
+// service, the log of cfg is moved to stderr then.
+func newReplayNotifyer(cfg *config.Config, service string, dryRun bool, out io.Writer) (notifyer.Notifyer, error) {
+	if dryRun {
+		// dry run messages go to stdout, keep the log out of them
+		cfg.SetLogOutput(os.Stderr)
+		return notifyer.NewPrintNotifyer(*cfg, out), nil
+	}
+
+	switch service {
+	case "telegram":
+		return notifyer.NewTelegramNotifyer(*cfg)
+	case "stdout":
+		cfg.SetLogOutput(os.Stderr)
+		return notifyer.NewPrintNotifyer(*cfg, out), nil
+	default:
+		return notifyer.Notifyer{}, fmt.Errorf("unknown service %q, expected telegram or stdout", service)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Config{
+		Logger:               logging.Discard(),
+		UpdatesToCheck:       []string{"Grade"},
+		ToPrint:              []string{"Title"},
+		ToPrintOnUpdates:     []string{"Grade"},
+		GradesHistoryPath:    filepath.Join(dir, "grades_history.jsonl"),
+		LastTimeNotifyedPath: filepath.Join(dir, "last_time_notifyed_time"),
+	}
+	grades := course.NewGrades(course.SaveConfig{GradesHistoryPath: cfg.GradesHistoryPath}, cfg.Logger)
+
+	change := func(field, from, to string) []course.CourseGradesChange {
+		fromRow := moodle.GradeReport{ID: 5, Title: "Lab 4", Grade: "8", Feedback: "ok"}
+		toRow := fromRow
+		switch field {
+		case "Grade":
+			toRow.Grade = to
+			fromRow.Grade = from
+		case "Feedback":
+			toRow.Feedback = to
+			fromRow.Feedback = from
+		}
+
+		return []course.CourseGradesChange{{
+			Course: moodle.Course{
+				ID:       42,
+				Fullname: "AGLA",
+				Grades:   []moodle.GradeReport{toRow},
+			},
+			GradesTableChange: []course.GradeRowChange{{ID: 5, Type: "update", Fields: []string{field}, From: fromRow, To: toRow}},
+			TotalChange:       course.CourseTotalChange{Type: "nochange"},
+		}}
+	}
+
+	day := time.Date(2023, time.May, 19, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, grades.SaveHistory(change("Grade", "8", "9"), day))
+	assert.NoError(t, grades.SaveHistory(change("Feedback", "ok", "good"), day.Add(time.Hour)))
+
+	history, err := grades.GetHistory(time.Time{}, time.Time{})
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	t.Run("records are sent through the current filter", func(t *testing.T) {
+		for _, record := range history {
+			assert.Nil(t, record.Updates[0].Course.Grades)
+		}
+
+		out := &bytes.Buffer{}
+		notifications, err := newReplayNotifyer(&cfg, "stdout", false, out)
+		assert.NoError(t, err)
+
+		sent, err := sendHistory(context.Background(), &notifications, history, cfg.Logger)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent, "feedback is not in updatesToCheck")
+		assert.Contains(t, out.String(), "AGLA")
+		assert.Contains(t, out.String(), `"8"  ->  "9"`)
+		assert.NotContains(t, out.String(), "good")
+	})
+
+	t.Run("dry run prints instead of sending", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		notifications, err := newReplayNotifyer(&cfg, "telegram", true, out)
+		assert.NoError(t, err)
+
+		sent, err := sendHistory(context.Background(), &notifications, history, cfg.Logger)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		assert.Contains(t, out.String(), "AGLA")
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		_, err := newReplayNotifyer(&cfg, "email", false, &bytes.Buffer{})
+
+		assert.EqualError(t, err, `unknown service "email", expected telegram or stdout`)
+	})
+
+	t.Run("interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		notifications, err := newReplayNotifyer(&cfg, "stdout", false, &bytes.Buffer{})
+		assert.NoError(t, err)
+
+		_, err = sendHistory(ctx, &notifications, history, cfg.Logger)
+
+		assert.EqualError(t, err, "replay interrupted after 0 messages")
+	})
+}
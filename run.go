@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/failure"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/logging"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/metrics"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/redact"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/scheduler"
+)
+
+// app holds everything made from the config, it is remade on SIGHUP. The
+// digest and the failures outlive reloads.
+type app struct {
+	log      *slog.Logger
+	redactor redact.Redactor
+	notifyer *notifyer.Notifyer
+	schedule scheduler.Scheduler
+	checker  checker
+	dryRun   bool
+	digest   *course.Digest
+	failures *failure.Tracker
+}
+
+// runDaemon checks grades on schedule until SIGINT or SIGTERM. The config is
+// reloaded on SIGHUP or when the file changes.
+func runDaemon(args []string) error {
+	flags := newFlagSet("run")
+	dryRun := flags.Bool("dry-run", false, "print the messages instead of sending them, do not save grades")
+	flags.Parse(args)
+
+	a, err := loadApp(*dryRun)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	reload := make(chan struct{}, 1)
+	go forwardSignal(ctx, syscall.SIGHUP, reload)
+	go watchFile(ctx, configPath, configWatchInterval, reload)
+
+	if a.checker.cfg.MetricsAddr != "" {
+		err = metrics.Serve(ctx, a.checker.cfg.MetricsAddr, a.log)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		err := a.checker.check(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+
+		var delay time.Duration
+		if err != nil {
+			// the failure is logged by the check
+			var shouldAlert bool
+			delay, shouldAlert = a.failures.Failed(err)
+			if shouldAlert {
+				alert := fmt.Sprintf("Checks are failing, %v in a row, last error:\n%v", a.failures.Failures(), err)
+				a.sendAlert(alert)
+			}
+
+			if failure.KindOf(err) == failure.KindAuth {
+				return fmt.Errorf("credentials were rejected, stopping")
+			}
+		} else if a.failures.Succeeded() {
+			a.sendAlert("Checks have recovered")
+		}
+
+		a = waitForNextCheck(ctx, a, reload, time.Now(), err, delay)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	a.log.Info("shutting down")
+
+	return nil
+}
+
+// waitForNextCheck waits for the check after the one finished at checkedAt
+// and returns the app reloaded meanwhile. The time of the next check is kept
+// over reloads unless the schedule is changed, so frequent reloads do not put
+// checks off.
+func waitForNextCheck(
+	ctx context.Context,
+	a app,
+	reload <-chan struct{},
+	checkedAt time.Time,
+	checkErr error,
+	delay time.Duration,
+) app {
+	nextCheck := a.nextCheck(checkedAt, checkErr, delay)
+	wait := func(ctx context.Context) error {
+		return a.schedule.WaitUntil(ctx, nextCheck)
+	}
+
+	for waitOrReload(ctx, reload, wait) {
+		reloaded := a.reload()
+		if !reloaded.schedule.Equal(a.schedule) {
+			nextCheck = reloaded.nextCheck(checkedAt, checkErr, delay)
+		}
+		a = reloaded
+	}
+
+	return a
+}
+
+// nextCheck returns the time of the check after the one finished at
+// checkedAt, a failed check is retried after delay.
+func (a app) nextCheck(checkedAt time.Time, checkErr error, delay time.Duration) time.Time {
+	if checkErr != nil {
+		return a.schedule.NextRetry(checkedAt, delay)
+	}
+
+	return a.schedule.NextCheck(checkedAt)
+}
+
+// checkOnce runs a single check, e.g. from cron or a systemd timer.
+func checkOnce(args []string) error {
+	flags := newFlagSet("check")
+	dryRun := flags.Bool("dry-run", false, "print the messages instead of sending them, do not save grades")
+	flags.Parse(args)
+
+	a, err := loadApp(*dryRun)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	return a.checker.check(ctx)
+}
+
+func loadApp(dryRun bool) (app, error) {
+	cfg, err := config.LoadConfig(configPath, overrides)
+	if err != nil {
+		return app{}, fmt.Errorf("failed to get configuration: %v", err)
+	}
+
+	digest := course.NewDigest(cfg.DigestPath, cfg.Logger)
+	failures := failure.NewTracker(failure.DefaultBackoff(), 0)
+
+	return newApp(cfg, dryRun, digest, failures)
+}
+
+func newApp(
+	cfg config.Config,
+	dryRun bool,
+	digest *course.Digest,
+	failures *failure.Tracker,
+) (app, error) {
+	schedule, err := scheduler.NewScheduler(scheduler.Config{
+		Schedules:       cfg.Schedules,
+		CheckInterval:   cfg.CheckInterval,
+		QuietHoursStart: cfg.QuietHoursStart,
+		QuietHoursEnd:   cfg.QuietHoursEnd,
+		TimeZone:        cfg.TimeZone,
+	}, cfg.Logger)
+	if err != nil {
+		return app{}, err
+	}
+
+	failures.Configure(failure.Backoff{
+		Base:   cfg.FailedRequestRepeatTimeout,
+		Max:    cfg.BackoffMax,
+		Jitter: failure.DefaultBackoff().Jitter,
+	}, cfg.AlertAfterFailures)
+
+	notifications := notifyer.NewPrintNotifyer(cfg, os.Stdout)
+	if !dryRun {
+		notifications, err = notifyer.NewTelegramNotifyer(cfg)
+		if err != nil {
+			return app{}, err
+		}
+	}
+
+	a := app{
+		log:      cfg.Logger,
+		redactor: cfg.Redactor,
+		notifyer: &notifications,
+		schedule: schedule,
+		checker:  newChecker(cfg, &notifications, schedule, digest, dryRun),
+		dryRun:   dryRun,
+		digest:   digest,
+		failures: failures,
+	}
+
+	return a, nil
+}
+
+// reload remakes the app from the config file, the running app is kept if
+// the config is invalid. The app is replaced between checks, so a check
+// never sees a half reloaded config.
+func (a app) reload() app {
+	a.log.Info("reloading config", "path", configPath)
+
+	cfg, err := config.LoadConfig(configPath, overrides)
+	if err != nil {
+		a.log.Error("failed to reload config, keeping the old one", logging.Err(err))
+		return a
+	}
+
+	reloaded, err := newApp(cfg, a.dryRun, a.digest, a.failures)
+	if err != nil {
+		a.log.Error("failed to reload config, keeping the old one", logging.Err(err))
+		return a
+	}
+	reloaded.notifyer.KeepSent(a.notifyer)
+
+	if cfg.MetricsAddr != a.checker.cfg.MetricsAddr {
+		reloaded.log.Warn("metricsAddr is changed on restart only", "addr", a.checker.cfg.MetricsAddr)
+	}
+
+	reloaded.log.Info("config reloaded")
+
+	return reloaded
+}
+
+// sendAlert sends the alert to the chat, the errors in it may hold secrets.
+func (a app) sendAlert(alert string) {
+	ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+	defer cancel()
+
+	err := a.notifyer.SendAlert(ctx, a.redactor.String(alert))
+	if err != nil {
+		a.log.Error("failed to send alert", logging.Err(err))
+	}
+}
+
+// waitOrReload runs wait until it is over, ctx is done or the config should
+// be reloaded. It reports whether the config should be reloaded.
+func waitOrReload(
+	ctx context.Context,
+	reload <-chan struct{},
+	wait func(context.Context) error,
+) bool {
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- wait(waitCtx)
+	}()
+
+	select {
+	case <-reload:
+		cancel()
+		<-done
+		return true
+	case <-done:
+		return false
+	}
+}
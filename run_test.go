@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/notifyer/formatter"
+)
+
+func TestWaitOrReload(t *testing.T) {
+	waitForever := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	t.Run("wait is over", func(t *testing.T) {
+		isReload := waitOrReload(context.Background(), make(chan struct{}), func(ctx context.Context) error {
+			return nil
+		})
+
+		assert.False(t, isReload)
+	})
+
+	t.Run("reload stops the wait", func(t *testing.T) {
+		reload := make(chan struct{}, 1)
+		notify(reload)
+
+		isReload := waitOrReload(context.Background(), reload, waitForever)
+
+		assert.True(t, isReload)
+	})
+
+	t.Run("done ctx", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		isReload := waitOrReload(ctx, make(chan struct{}), waitForever)
+
+		assert.False(t, isReload)
+	})
+}
+
+// useTestConfig points configPath to a config file in a temporary directory
+// for the test, writeConfig rewrites the file with checkInterval.
+func useTestConfig(t *testing.T) (writeConfig func(checkInterval string)) {
+	dir := t.TempDir()
+	t.Setenv(config.EnvMoodleToken, "token")
+	t.Setenv(config.EnvTelegramBotKey, "key")
+	t.Setenv(config.EnvTelegramChatID, "1")
+
+	path := filepath.Join(dir, "config.json")
+	writeConfig = func(checkInterval string) {
+		cfgJSON := `{
+			"checkInterval": ` + checkInterval + `,
+			"updatesToCheck": ["Grade"],
+			"toPrint": ["Title"],
+			"toPrintOnUpdates": ["Grade"],
+			"lastGradesPath": "` + filepath.Join(dir, "last_grades.json") + `",
+			"gradesHistoryPath": "` + filepath.Join(dir, "grades_history.jsonl") + `",
+			"lastTimeNotifyedPath": "` + filepath.Join(dir, "last_time_notifyed_time") + `"
+		}`
+		assert.NoError(t, os.WriteFile(path, []byte(cfgJSON), 0644))
+	}
+
+	previousConfigPath := configPath
+	configPath = path
+	t.Cleanup(func() { configPath = previousConfigPath })
+
+	return writeConfig
+}
+
+func TestReload(t *testing.T) {
+	writeConfig := useTestConfig(t)
+
+	writeConfig("3600")
+	a, err := loadApp(true)
+	assert.NoError(t, err)
+
+	warnings := []formatter.Warning{{Course: "AGLA", Message: "row 3 could not be parsed"}}
+	sent, err := a.notifyer.SendWarnings(context.Background(), warnings)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	t.Run("invalid config keeps the old app", func(t *testing.T) {
+		writeConfig("-1")
+
+		reloaded := a.reload()
+
+		assert.Equal(t, time.Hour, reloaded.checker.cfg.CheckInterval)
+		assert.Same(t, a.notifyer, reloaded.notifyer)
+	})
+
+	t.Run("valid config replaces the app", func(t *testing.T) {
+		writeConfig("60")
+
+		reloaded := a.reload()
+
+		assert.Equal(t, time.Minute, reloaded.checker.cfg.CheckInterval)
+		assert.NotSame(t, a.notifyer, reloaded.notifyer)
+		assert.Same(t, a.digest, reloaded.digest)
+		assert.Same(t, a.failures, reloaded.failures)
+
+		sent, err := reloaded.notifyer.SendWarnings(context.Background(), warnings)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, sent, "sent warnings are kept over the reload")
+	})
+}
+
+func TestWaitForNextCheck(t *testing.T) {
+	writeConfig := useTestConfig(t)
+
+	// reloads until the wait is over
+	reloadUntilDone := func(done <-chan struct{}, reload chan<- struct{}) {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				notify(reload)
+			}
+		}
+	}
+
+	t.Run("reloads keep the next check", func(t *testing.T) {
+		writeConfig(`"300ms"`)
+		a, err := loadApp(true)
+		assert.NoError(t, err)
+
+		reload := make(chan struct{}, 1)
+		done := make(chan struct{})
+		go reloadUntilDone(done, reload)
+
+		start := time.Now()
+		waitForNextCheck(context.Background(), a, reload, start, nil, 0)
+		close(done)
+
+		assert.True(t, time.Since(start) < time.Second, "the next check is put off by %v", time.Since(start))
+	})
+
+	t.Run("changed schedule moves the next check", func(t *testing.T) {
+		writeConfig("3600")
+		a, err := loadApp(true)
+		assert.NoError(t, err)
+
+		reload := make(chan struct{}, 1)
+		writeConfig(`"300ms"`)
+		notify(reload)
+
+		start := time.Now()
+		reloaded := waitForNextCheck(context.Background(), a, reload, start, nil, 0)
+
+		assert.True(t, time.Since(start) < time.Second)
+		assert.Equal(t, 300*time.Millisecond, reloaded.checker.cfg.CheckInterval)
+	})
+
+	t.Run("failed check is retried after the delay", func(t *testing.T) {
+		writeConfig("3600")
+		a, err := loadApp(true)
+		assert.NoError(t, err)
+
+		start := time.Now()
+		waitForNextCheck(context.Background(), a, make(chan struct{}), start, errors.New("moodle is down"), 100*time.Millisecond)
+
+		assert.True(t, time.Since(start) >= 100*time.Millisecond)
+		assert.True(t, time.Since(start) < time.Second)
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aDeepRecession/moodle-scrapper/pkg/config"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/course"
+	"github.com/aDeepRecession/moodle-scrapper/pkg/moodle"
+)
+
+// showStatus prints the last saved snapshot and the changes queued for the
+// quiet hours digest.
+func showStatus(args []string) error {
+	flags := newFlagSet("status")
+	flags.Parse(args)
+
+	cfg, err := config.LoadConfig(configPath, overrides)
+	if err != nil {
+		return fmt.Errorf("failed to get configuration: %v", err)
+	}
+
+	snapshotInfo, err := os.Stat(cfg.LastGradesPath)
+	if err != nil {
+		return fmt.Errorf("failed to get status: no snapshot: %v", err)
+	}
+
+	grades := course.NewGrades(course.SaveConfig{LastGradesPath: cfg.LastGradesPath}, cfg.Logger)
+	snapshot, err := grades.GetSaved()
+	if err != nil {
+		return fmt.Errorf("failed to get status: %v", err)
+	}
+
+	digest := course.NewDigest(cfg.DigestPath, cfg.Logger)
+
+	printStatus(os.Stdout, snapshotInfo.ModTime(), snapshot, digest)
+
+	return nil
+}
+
+func printStatus(out io.Writer, snapshotTime time.Time, snapshot []moodle.Course, digest *course.Digest) {
+	fmt.Fprintf(out, "config:              %s\n", configPath)
+	fmt.Fprintf(
+		out,
+		"last snapshot:       %s (%s ago)\n",
+		snapshotTime.Format("2006-01-02 15:04:05"),
+		time.Since(snapshotTime).Round(time.Second),
+	)
+
+	// the snapshot keeps the courses that are no longer tracked as well, the
+	// filter is not applied as it needs moodle
+	fmt.Fprintf(out, "courses in snapshot: %d\n", len(snapshot))
+	for _, snapshotCourse := range snapshot {
+		total := snapshotCourse.Total.Grade
+		if total == "" {
+			total = "-"
+		}
+
+		fmt.Fprintf(
+			out,
+			"  %s (%d): %d grades, total %s\n",
+			snapshotCourse.Fullname,
+			snapshotCourse.ID,
+			len(snapshotCourse.Grades),
+			total,
+		)
+	}
+
+	if !digest.IsOpen() {
+		fmt.Fprintf(out, "pending notifications: none\n")
+		return
+	}
+
+	pending := digest.Pending(snapshot)
+	fmt.Fprintf(out, "pending notifications: %d courses changed during quiet hours\n", len(pending))
+	for _, courseChange := range pending {
+		fmt.Fprintf(
+			out,
+			"  %s: %d grade changes\n",
+			courseChange.Course.Fullname,
+			len(courseChange.GradesTableChange),
+		)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+)
+
+const configWatchInterval = 2 * time.Second
+
+// forwardSignal notifies reload on every sig until ctx is done.
+func forwardSignal(ctx context.Context, sig os.Signal, reload chan<- struct{}) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, sig)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			notify(reload)
+		}
+	}
+}
+
+// watchFile notifies changed when the modification time or the size of the
+// file changes. Polling works on every platform and with editors that
+// replace the file on save, a file missing for a moment is not a change.
+func watchFile(ctx context.Context, path string, interval time.Duration, changed chan<- struct{}) {
+	last, _ := os.Stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		isChanged := last == nil || !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size()
+		last = info
+		if isChanged {
+			notify(changed)
+		}
+	}
+}
+
+// notify does not block, a pending notification is enough.
+func notify(ch chan<- struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
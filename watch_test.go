@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	go watchFile(ctx, path, 10*time.Millisecond, changed)
+
+	t.Run("unchanged file", func(t *testing.T) {
+		select {
+		case <-changed:
+			t.Fatal("unchanged file is reported")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("file missing for a moment", func(t *testing.T) {
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NoError(t, os.Remove(path))
+		time.Sleep(50 * time.Millisecond)
+
+		select {
+		case <-changed:
+			t.Fatal("missing file is reported")
+		default:
+		}
+
+		assert.NoError(t, os.WriteFile(path, data, 0644))
+	})
+
+	t.Run("changed file", func(t *testing.T) {
+		// drains the change a restored file may be taken for
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case <-changed:
+		default:
+		}
+
+		assert.NoError(t, os.WriteFile(path, []byte(`{"checkInterval": 60}`), 0644))
+
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+			t.Fatal("changed file is not reported")
+		}
+	})
+}
+
+func TestForwardSignal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the signal would kill the test before forwardSignal subscribes to it
+	ignored := make(chan os.Signal, 1)
+	signal.Notify(ignored, syscall.SIGUSR1)
+	defer signal.Stop(ignored)
+
+	reload := make(chan struct{}, 1)
+	go forwardSignal(ctx, syscall.SIGUSR1, reload)
+
+	// forwardSignal subscribes in its goroutine, the signal is sent until it
+	// is forwarded
+	deadline := time.After(time.Second)
+	for {
+		assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+		select {
+		case <-reload:
+			return
+		case <-deadline:
+			t.Fatal("signal is not forwarded")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}